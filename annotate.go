@@ -0,0 +1,111 @@
+package log
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// maximumAnnotations bounds the annotations of one request
+	maximumAnnotations = 32
+
+	// maximumTags bounds the tags of one request
+	maximumTags = 64
+)
+
+// safe typing https://golang.org/pkg/context/#WithValue
+type annotationsKeyType string
+
+const annotationsKey annotationsKeyType = "annotations"
+
+// annotations are the handler outcomes attached to the response line and
+// the tags attached to every line of the request
+type annotations struct {
+	mu     sync.Mutex
+	values map[string]interface{}
+	tags   map[string]string
+}
+
+// withAnnotations makes ctx collect the values given to Annotate and Tag
+func withAnnotations(ctx context.Context) context.Context {
+	return context.WithValue(ctx, annotationsKey, &annotations{
+		values: make(map[string]interface{}),
+		tags:   make(map[string]string),
+	})
+}
+
+// Annotate records a handler outcome, e.g. user_tier or cache_hit, added as
+// a field to the response line that Middleware writes for the request. The
+// annotations live in the request context next to the data map; without
+// Middleware, or past 32 distinct keys per request, Annotate does nothing.
+func Annotate(ctx context.Context, key string, value interface{}) {
+	a, ok := ctx.Value(annotationsKey).(*annotations)
+	if !ok {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.values[key]; !exists && len(a.values) >= maximumAnnotations {
+		return
+	}
+	a.values[key] = value
+}
+
+// MarkCacheHit records whether the response was served from a cache, added
+// as a "cache_hit" boolean to the response line of Middleware. The field is
+// absent when the handler never marks the request.
+func MarkCacheHit(ctx context.Context, hit bool) {
+	Annotate(ctx, CacheHitKey, hit)
+}
+
+// Tag adds key and value to every later line of the request, the access
+// log lines of Middleware included, and to no line once the request is
+// over. A tag wins over the data map entry of the same name. Like Annotate
+// it is safe from any goroutine of the request and does nothing without
+// Middleware, past 64 distinct keys per request, or for context_id.
+func Tag(ctx context.Context, key, value string) {
+	a, ok := ctx.Value(annotationsKey).(*annotations)
+	if !ok || key == ContextIdKey {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.tags[key]; !exists && len(a.tags) >= maximumTags {
+		return
+	}
+	a.tags[key] = value
+}
+
+func (lp *LogParams) injectTags(ctx context.Context) *LogParams {
+	a, ok := ctx.Value(annotationsKey).(*annotations)
+	if !ok {
+		return lp
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, value := range a.tags {
+		lp.fields[key] = value
+	}
+	return lp
+}
+
+func (lp *LogParams) injectAnnotations(ctx context.Context) *LogParams {
+	a, ok := ctx.Value(annotationsKey).(*annotations)
+	if !ok {
+		return lp
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for key, value := range a.values {
+		lp.fields[key] = value
+	}
+	return lp
+}
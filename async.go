@@ -0,0 +1,157 @@
+package log
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// asyncLine is a formatted line, or a flush request when flushed is set
+type asyncLine struct {
+	line    []byte
+	flushed chan struct{}
+}
+
+// asyncWriter writes lines on a background goroutine. Lines arrive fully
+// formatted and are copied before being queued, so the values logged are
+// the ones at the log call whatever the caller changes afterwards.
+type asyncWriter struct {
+	w     io.Writer
+	lines chan asyncLine
+	done  chan struct{}
+
+	// mu guards closed against a Write racing Close
+	mu     sync.RWMutex
+	closed bool
+
+	// batchEntries and batchWait are the batching window of SetBatch
+	batchEntries atomic.Int64
+	batchWait    atomic.Int64
+}
+
+func newAsyncWriter(w io.Writer, buffer int) *asyncWriter {
+	a := &asyncWriter{
+		w:     w,
+		lines: make(chan asyncLine, buffer),
+		done:  make(chan struct{}),
+	}
+	go a.drain()
+
+	return a
+}
+
+func (a *asyncWriter) drain() {
+	defer close(a.done)
+
+	var (
+		batch   []byte
+		entries int64
+		timer   *time.Timer
+		timeout <-chan time.Time
+	)
+	write := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, timeout = nil, nil
+		}
+		if entries > 0 {
+			a.w.Write(batch)
+			batch, entries = batch[:0], 0
+		}
+	}
+
+	for {
+		select {
+		case line, ok := <-a.lines:
+			if !ok {
+				write()
+				return
+			}
+			if line.flushed != nil {
+				write()
+				close(line.flushed)
+				continue
+			}
+
+			maxEntries := a.batchEntries.Load()
+			if maxEntries <= 1 {
+				write()
+				a.w.Write(line.line)
+				continue
+			}
+			batch = append(batch, line.line...)
+			entries++
+			if entries >= maxEntries {
+				write()
+				continue
+			}
+			if maxWait := time.Duration(a.batchWait.Load()); timer == nil && maxWait > 0 {
+				timer = time.NewTimer(maxWait)
+				timeout = timer.C
+			}
+		case <-timeout:
+			timer, timeout = nil, nil
+			write()
+		}
+	}
+}
+
+// SetBatch groups the lines of an asynchronous logger, see
+// Config.AsyncBuffer, into writes of up to maxEntries lines, the first line
+// of a batch waiting at most maxWait for the others. Larger batches mean
+// fewer writes, and more throughput to slow sinks, at the cost of lines
+// reaching the output later. A zero maxWait waits for a full batch. Flush,
+// Close and Shutdown write a partial batch at once. A maxEntries of one or
+// less writes every line on its own, as by default. It does nothing on a
+// synchronous logger.
+func (l *Log) SetBatch(maxEntries int, maxWait time.Duration) {
+	if a, ok := l.entry.Logger.Out.(*asyncWriter); ok {
+		a.batchWait.Store(int64(maxWait))
+		a.batchEntries.Store(int64(maxEntries))
+	}
+}
+
+// Write queues a copy of p, it blocks while the queue is full and drops p
+// once the writer is closed
+func (a *asyncWriter) Write(p []byte) (int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if !a.closed {
+		a.lines <- asyncLine{line: append([]byte(nil), p...)}
+	}
+
+	return len(p), nil
+}
+
+// Flush returns once every line queued before the call is written
+func (a *asyncWriter) Flush() error {
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return nil
+	}
+	flushed := make(chan struct{})
+	a.lines <- asyncLine{flushed: flushed}
+	a.mu.RUnlock()
+
+	<-flushed
+	return nil
+}
+
+// Close writes the queued lines, stops the background goroutine, then
+// flushes and closes the wrapped writer like Shutdown does
+func (a *asyncWriter) Close() error {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.lines)
+	a.mu.Unlock()
+
+	<-a.done
+	return closeOutputs([]io.Writer{unwrapWriter(a.w)})
+}
@@ -0,0 +1,144 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+// lockedBuffer is a bytes.Buffer safe to read while the logger writes
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// TestAsyncSnapshotsFields is meant to run with -race as well
+func TestAsyncSnapshotsFields(t *testing.T) {
+	var out lockedBuffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	asyncLogger := newLog(logger, Config{Service: sampleString, AsyncBuffer: 16})
+
+	order := map[string]interface{}{"status": "pending"}
+	items := []string{"a"}
+	asyncLogger.InfoMap(sampleContext, map[string]interface{}{"order": order, "items": items}, "queued")
+
+	order["status"] = "paid"
+	items[0] = "b"
+
+	assert.Nil(t, asyncLogger.Shutdown(context.Background()))
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, map[string]interface{}{"status": "pending"}, line["order"])
+	assert.Equal(t, []interface{}{"a"}, line["items"])
+
+	// lines after Shutdown are dropped rather than panicking
+	asyncLogger.Info(sampleContext, "late")
+}
+
+// countingWriter counts the writes it receives
+type countingWriter struct {
+	lockedBuffer
+	writes atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes.Add(1)
+	return w.lockedBuffer.Write(p)
+}
+
+func TestAsyncBatch(t *testing.T) {
+	var out countingWriter
+	logger := log.New()
+	logger.SetOutput(&out)
+	asyncLogger := newLog(logger, Config{Service: sampleString, AsyncBuffer: 16})
+	asyncLogger.SetBatch(3, time.Hour)
+
+	for i := 0; i < 4; i++ {
+		asyncLogger.Info(sampleContext, sampleString)
+	}
+	assert.Nil(t, asyncLogger.entry.Logger.Out.(*asyncWriter).Flush())
+
+	// one full batch, then the partial one written by Flush
+	assert.Equal(t, int64(2), out.writes.Load())
+	assert.Equal(t, 4, bytes.Count(out.Bytes(), []byte("\n")))
+
+	// the window writes a partial batch once maxWait elapsed
+	asyncLogger.SetBatch(10, 10*time.Millisecond)
+	asyncLogger.Info(sampleContext, sampleString)
+	deadline := time.Now().Add(time.Second)
+	for out.writes.Load() < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, int64(3), out.writes.Load())
+
+	asyncLogger.Info(sampleContext, sampleString)
+	assert.Nil(t, asyncLogger.Shutdown(context.Background()))
+	assert.Equal(t, 6, bytes.Count(out.Bytes(), []byte("\n")))
+}
+
+// flushingBuffer only shows what was written once flushed
+type flushingBuffer struct {
+	lockedBuffer
+	pending bytes.Buffer
+}
+
+func (b *flushingBuffer) Write(p []byte) (int, error) {
+	return b.pending.Write(p)
+}
+
+func (b *flushingBuffer) Flush() error {
+	_, err := b.lockedBuffer.Write(b.pending.Bytes())
+	b.pending.Reset()
+	return err
+}
+
+func TestFatalAndPanicLinesReachAsyncOutputs(t *testing.T) {
+	var out lockedBuffer
+	var output flushingBuffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	exited := 0
+	logger.ExitFunc = func(code int) { exited = code }
+	asyncLogger := newLog(logger, Config{Service: sampleString, AsyncBuffer: 16})
+	outputLogger := newLog(log.New(), Config{Service: sampleString, Outputs: []Output{{Writer: &output}}})
+	outputLogger.entry.Logger.ExitFunc = func(int) {}
+
+	asyncLogger.Fatal(sampleContext, "fatal line")
+	assert.Equal(t, 1, exited)
+	assert.True(t, bytes.Contains(out.Bytes(), []byte("fatal line")))
+
+	outputLogger.Fatal(sampleContext, "fatal line")
+	assert.True(t, bytes.Contains(output.Bytes(), []byte("fatal line")))
+
+	for _, l := range []*Log{asyncLogger, outputLogger} {
+		func() {
+			defer func() { recover() }()
+			l.Panic(sampleContext, "panic line")
+		}()
+	}
+	assert.True(t, bytes.Contains(out.Bytes(), []byte("panic line")))
+	assert.True(t, bytes.Contains(output.Bytes(), []byte("panic line")))
+}
@@ -0,0 +1,115 @@
+package log
+
+import (
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BackpressurePolicy tells what a line does when Config.MaxConcurrentWrites
+// writes are already in progress.
+type BackpressurePolicy int
+
+const (
+	// BlockWhenFull waits for a write to complete.
+	BlockWhenFull BackpressurePolicy = iota
+
+	// DropWhenFull loses the line at once.
+	DropWhenFull
+
+	// TimeoutWhenFull waits up to Config.BackpressureTimeout, then loses
+	// the line.
+	TimeoutWhenFull
+)
+
+// WriteStats counts the lines held back by Config.MaxConcurrentWrites since
+// the logger was built.
+type WriteStats struct {
+	// Waited counts the lines that waited for a write slot
+	Waited uint64
+
+	// Dropped counts the lines lost, timed out ones included
+	Dropped uint64
+
+	// TimedOut counts the lines lost after waiting BackpressureTimeout
+	TimedOut uint64
+}
+
+// writeLimit bounds the writes in progress, shared by every logger derived
+// from the same root
+type writeLimit struct {
+	slots   chan struct{}
+	policy  BackpressurePolicy
+	timeout time.Duration
+
+	waited   atomic.Uint64
+	dropped  atomic.Uint64
+	timedOut atomic.Uint64
+}
+
+func newWriteLimit(cfg *Config) *writeLimit {
+	w := &writeLimit{policy: cfg.BackpressurePolicy, timeout: cfg.BackpressureTimeout}
+	if cfg.MaxConcurrentWrites > 0 {
+		w.slots = make(chan struct{}, cfg.MaxConcurrentWrites)
+	}
+
+	return w
+}
+
+// acquire takes a write slot for a line at level, it reports false when the
+// line must be dropped. Fatal and Panic lines always wait.
+func (w *writeLimit) acquire(level log.Level) bool {
+	if w.slots == nil {
+		return true
+	}
+
+	select {
+	case w.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	policy := w.policy
+	if level <= log.FatalLevel {
+		policy = BlockWhenFull
+	}
+
+	switch policy {
+	case DropWhenFull:
+		w.dropped.Add(1)
+		return false
+	case TimeoutWhenFull:
+		w.waited.Add(1)
+		timer := time.NewTimer(w.timeout)
+		defer timer.Stop()
+
+		select {
+		case w.slots <- struct{}{}:
+			return true
+		case <-timer.C:
+			w.timedOut.Add(1)
+			w.dropped.Add(1)
+			return false
+		}
+	default:
+		w.waited.Add(1)
+		w.slots <- struct{}{}
+		return true
+	}
+}
+
+func (w *writeLimit) release() {
+	if w.slots != nil {
+		<-w.slots
+	}
+}
+
+// WriteStats returns the lines held back by Config.MaxConcurrentWrites.
+func (l *Log) WriteStats() WriteStats {
+	return WriteStats{
+		Waited:   l.writeLimit.waited.Load(),
+		Dropped:  l.writeLimit.dropped.Load(),
+		TimedOut: l.writeLimit.timedOut.Load(),
+	}
+}
@@ -0,0 +1,95 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// blockingWriter holds every write until release is closed
+type blockingWriter struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.entered <- struct{}{}
+	<-w.release
+	return len(p), nil
+}
+
+func TestBackpressurePolicies(t *testing.T) {
+	for _, policy := range []BackpressurePolicy{DropWhenFull, TimeoutWhenFull} {
+		writer := &blockingWriter{entered: make(chan struct{}, 8), release: make(chan struct{})}
+		logger := log.New()
+		logger.SetOutput(writer)
+		slowLogger := newLog(logger, Config{
+			Service:             sampleString,
+			MaxConcurrentWrites: 1,
+			BackpressurePolicy:  policy,
+			BackpressureTimeout: time.Millisecond,
+		})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			slowLogger.Info(sampleContext, "first")
+		}()
+		<-writer.entered
+
+		slowLogger.Info(sampleContext, "held back")
+		close(writer.release)
+		<-done
+
+		stats := slowLogger.WriteStats()
+		assert.Equal(t, uint64(1), stats.Dropped)
+		if policy == TimeoutWhenFull {
+			assert.Equal(t, uint64(1), stats.Waited)
+			assert.Equal(t, uint64(1), stats.TimedOut)
+		} else {
+			assert.Equal(t, uint64(0), stats.Waited)
+		}
+	}
+}
+
+func TestBackpressureDroppedLinesAreNotMirrored(t *testing.T) {
+	writer := &blockingWriter{entered: make(chan struct{}, 8), release: make(chan struct{})}
+	logger := log.New()
+	logger.SetOutput(writer)
+	slowLogger := newLog(logger, Config{
+		Service:             sampleString,
+		MaxConcurrentWrites: 1,
+		BackpressurePolicy:  DropWhenFull,
+	})
+
+	span := &recordingSpan{spanContext: trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})}
+	tracer := slowLogger.Tracer(trace.ContextWithSpan(sampleContext, span))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tracer.Info("first")
+	}()
+	<-writer.entered
+
+	tracer.Info("held back")
+	close(writer.release)
+	<-done
+
+	assert.Equal(t, uint64(1), slowLogger.WriteStats().Dropped)
+	assert.Equal(t, 1, len(span.events))
+	assert.Equal(t, "first", span.attributes["log.message"])
+}
+
+func TestBackpressureUnbounded(t *testing.T) {
+	limit := newWriteLimit(&Config{})
+	for i := 0; i < 3; i++ {
+		assert.True(t, limit.acquire(log.InfoLevel))
+	}
+}
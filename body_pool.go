@@ -0,0 +1,88 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"unicode/utf8"
+)
+
+// maximumPooledBody is the capacity above which a body buffer is left to
+// the garbage collector rather than kept in the pool
+const maximumPooledBody = 1 << 20
+
+var bodyBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// safe typing https://golang.org/pkg/context/#WithValue
+type bodyBuffersKeyType string
+
+const bodyBuffersKey bodyBuffersKeyType = "body_buffers"
+
+// bodyBuffers are the pooled buffers holding the request body replayed to
+// the handler, returned to the pool once Middleware wrote the completion.
+// The logged fields are copies, so no line, queued by AsyncBuffer or not,
+// refers to them.
+type bodyBuffers struct {
+	mu      sync.Mutex
+	buffers []*bytes.Buffer
+}
+
+func withBodyBuffers(ctx context.Context) (context.Context, *bodyBuffers) {
+	b := &bodyBuffers{}
+	return context.WithValue(ctx, bodyBuffersKey, b), b
+}
+
+// bodyBuffer returns an empty buffer for a body of the request of ctx,
+// taken from the pool when Middleware serves the request
+func bodyBuffer(ctx context.Context) *bytes.Buffer {
+	b, ok := ctx.Value(bodyBuffersKey).(*bodyBuffers)
+	if !ok {
+		return new(bytes.Buffer)
+	}
+
+	buf := bodyBufferPool.Get().(*bytes.Buffer)
+	b.mu.Lock()
+	b.buffers = append(b.buffers, buf)
+	b.mu.Unlock()
+	return buf
+}
+
+// replayBody is a request body replaying the part read for the log before
+// the rest, closing the original body
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// cutBody returns at most the first max bytes of body, on a rune boundary
+func cutBody(body []byte, max int) string {
+	if len(body) <= max {
+		return string(body)
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(body[cut]) {
+		cut--
+	}
+	return string(body[:cut])
+}
+
+// release returns the buffers to the pool, the handler must be done with
+// the request body
+func (b *bodyBuffers) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, buf := range b.buffers {
+		if buf.Cap() <= maximumPooledBody {
+			buf.Reset()
+			bodyBufferPool.Put(buf)
+		}
+	}
+	b.buffers = nil
+}
@@ -0,0 +1,20 @@
+package log
+
+// WithFields returns a logger adding fields to every line, e.g. the
+// database name for the lines of a repository, so they are not passed on
+// each call. Like service, they win over the fields of the same name given
+// to InfoMap and its siblings, and they are redacted and scrubbed like
+// them. The derived logger shares everything else, its level included,
+// with l.
+func (l *Log) WithFields(fields map[string]interface{}) Logger {
+	derived := *l
+	derived.entry = l.entry.WithFields(fields)
+	return &derived
+}
+
+// WithComponent returns a logger adding a "component" field to every line,
+// e.g. "db", "cache" or "http_client". Unlike Named it keeps the level of
+// l, see SetCategoryLevel for a level of its own.
+func (l *Log) WithComponent(name string) Logger {
+	return l.WithFields(map[string]interface{}{ComponentKey: name})
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestWithFields(t *testing.T) {
+	rootLogger, hook := NewLoggerWithTestHook(sampleString)
+	rootLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	dbLogger := rootLogger.WithComponent("db").WithFields(map[string]interface{}{"database": "orders", "shard": 3})
+	dbLogger.InfoMap(sampleContext, map[string]interface{}{"database": "spoofed", "rows": 2}, "query")
+
+	data := hook.LastEntry().Data
+	assert.Equal(t, "db", data[ComponentKey])
+	assert.Equal(t, "orders", data["database"])
+	assert.Equal(t, 3, data["shard"])
+	assert.Equal(t, 2, data["rows"])
+	assert.Equal(t, "11", data[ContextIdKey])
+	assert.Equal(t, sampleString, data[ServiceKey])
+
+	// the parent is left untouched
+	rootLogger.Info(sampleContext, sampleString)
+	_, ok := hook.LastEntry().Data[ComponentKey]
+	assert.False(t, ok)
+}
+
+func TestWithFieldsAreRedacted(t *testing.T) {
+	rootLogger := NewLoggerWithConfig(Config{Service: sampleString, RedactionRules: []RedactionRule{{Key: "card"}}})
+	rootLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(rootLogger.GetEntry().Logger)
+	rootLogger.SetPIIScrubbing(true)
+
+	fields := map[string]interface{}{"card": "4111111111111111", "email": "a@b.co"}
+	rootLogger.WithFields(fields).Info(sampleContext, "signup")
+
+	data := hook.LastEntry().Data
+	assert.Equal(t, RedactedValue, data["card"])
+	assert.Equal(t, RedactedValue, data["email"])
+
+	// as the same fields given to InfoMap
+	rootLogger.InfoMap(sampleContext, fields, "signup")
+	assert.Equal(t, data["card"], hook.LastEntry().Data["card"])
+	assert.Equal(t, data["email"], hook.LastEntry().Data["email"])
+
+	// and the rules in force when the line is written apply
+	rootLogger.SetRedactionEnabled(false)
+	rootLogger.SetPIIScrubbing(false)
+	rootLogger.WithFields(fields).Info(sampleContext, "signup")
+	assert.Equal(t, "4111111111111111", hook.LastEntry().Data["card"])
+}
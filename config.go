@@ -0,0 +1,547 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config holds the settings of a logger built by NewLoggerWithConfig.
+// The zero value behaves like NewLogger.
+type Config struct {
+	// Service is added to every line under the "service" key.
+	Service string
+
+	// Env is the deployment environment, e.g. production, added to every
+	// line under the "env" key. It defaults to the APP_ENV environment
+	// variable and the key is omitted when both are empty.
+	Env string
+
+	// SplitCallerLine reports the caller as a "file" path and a numeric
+	// "line" field instead of the combined "file:line" string.
+	SplitCallerLine bool
+
+	// ReportCaller adds the caller fields to the lines of every level, not
+	// only to Error and above. It takes precedence over CallerLevel.
+	ReportCaller bool
+
+	// CallerLevel is the least severe level whose lines get the caller
+	// fields, independently of the level lines are written at, e.g.
+	// WarnLevel on a logger writing Debug lines. Zero keeps ErrorLevel.
+	CallerLevel log.Level
+
+	// StringifyFields converts every field value to its string form before
+	// formatting, for backends whose schema only accepts string values.
+	StringifyFields bool
+
+	// MaxCollectionSize, when positive, logs the field values that are
+	// slices, arrays or maps of more elements as their type and length,
+	// e.g. "[]main.User(len=10000)", instead of their content. Nested
+	// values are kept whole.
+	MaxCollectionSize int
+
+	// OmitEmpty leaves empty fields out of the written lines, e.g. an
+	// unknown user_id instead of "user_id":"". KeepEmpty by default.
+	OmitEmpty OmitEmptyPolicy
+
+	// FlattenSeparator, when set, replaces nested map and struct field
+	// values with one field per leaf, keyed by the path joined with the
+	// separator, e.g. "user.id" and "user.name" for ".". Structs follow
+	// their JSON encoding and arrays are kept whole. A flattened key never
+	// replaces a field of the same name.
+	FlattenSeparator string
+
+	// FlattenDepth bounds how many levels FlattenSeparator unfolds, deeper
+	// values are kept nested. Defaults to 8 when zero.
+	FlattenDepth int
+
+	// FieldOrder switches to JSONFormatter and writes these keys first, in
+	// this order, the remaining keys sorted alphabetically. Use
+	// DefaultFieldOrder for time, level, service, env, context_id and msg.
+	FieldOrder []string
+
+	// Formatter renders the lines in place of the JSON formatter, e.g. a
+	// logrus.TextFormatter for local runs. FieldOrder is ignored when set.
+	Formatter log.Formatter
+
+	// LevelFormatters renders the lines of these levels with their own
+	// formatter, the others keep Formatter or JSON, e.g. a text formatter
+	// with the full fields for ErrorLevel. Outputs take a LevelFormatter
+	// instead.
+	LevelFormatters map[log.Level]log.Formatter
+
+	// LengthPrefixed writes every line as a frame, the length of the record
+	// as 4 big-endian bytes followed by the record without its newline, so
+	// a receiver reading a socket never scans for newlines. ReadFrame reads
+	// them back. It applies to the logger output and to Outputs.
+	LengthPrefixed bool
+
+	// Outputs sends every line to each of the outputs, rendered with the
+	// formatter of that output, instead of JSON on stderr.
+	Outputs []Output
+
+	// ErrorContextTemplate adds an "err_ctx" field to the lines at Error and
+	// above, one searchable token for aggregation tools that group on a
+	// single key. The {func}, {context_id} and {error_type} placeholders
+	// are replaced by the caller function, the context id and the type of
+	// the first error logged, see DefaultErrorContextTemplate.
+	ErrorContextTemplate string
+
+	// Sequence adds a "seq" field numbering the lines from 1, so a gap seen
+	// by the ingestion side reveals a lost line. The counter is shared by
+	// every logger derived from this one, Named included, and follows the
+	// order of the log calls: concurrent lines may be written slightly out
+	// of order. Lines dropped by MaxConcurrentWrites leave a gap too.
+	Sequence bool
+
+	// MaxMessageLength truncates longer messages, counted in bytes, and
+	// appends a "…[truncated]" suffix. Zero keeps messages whole.
+	MaxMessageLength int
+
+	// StrictContext reports the lines written with a context holding no
+	// context id, to find the code that fails to propagate it: a Warn line
+	// names each calling function the first time it does so.
+	StrictContext bool
+
+	// MissingContextId, with StrictContext, is called with the calling
+	// function of every line without context id instead of the Warn line,
+	// e.g. to fail a test through t.Errorf.
+	MissingContextId func(caller string)
+
+	// LogContextError adds a "ctx_err" field once the context of a line is
+	// canceled or past its deadline, and a "ctx_cause" field when
+	// context.Cause tells more than the error.
+	LogContextError bool
+
+	// LogDeadline adds a "deadline_remaining_ms" field to the lines whose
+	// context has a deadline, the time left until it when the line is
+	// written, negative once it passed.
+	LogDeadline bool
+
+	// ContextSampleRate keeps the Info, Debug and Trace lines of only this
+	// fraction of the context ids, e.g. 0.1, all the lines of a request or
+	// none of them, rather than a partial trace. The decision hashes the
+	// context id, so every instance agrees on it. Warn and more severe
+	// lines, and lines without a context id, are always kept. Zero, or one
+	// and above, keeps every context.
+	ContextSampleRate float64
+
+	// UnsafeSampleErrors lets sampling drop Warn and Error lines like the
+	// lower levels. Leave it off unless losing errors is acceptable; Fatal
+	// and Panic lines are never sampled.
+	UnsafeSampleErrors bool
+
+	// DryRun runs every line through the whole pipeline, formatter
+	// included, but discards the output. Combined with OnEntry it lets
+	// contract tests assert on what would be logged.
+	DryRun bool
+
+	// AsyncBuffer, when positive, moves the writes of the logger output to
+	// a background goroutine through a queue of that many lines. Lines are
+	// formatted and copied at the log call, so later changes to the logged
+	// values never show. A full queue blocks the caller; Shutdown writes
+	// the queued lines. Outputs are always written synchronously.
+	AsyncBuffer int
+
+	// MaxConcurrentWrites, when positive, bounds the lines being written at
+	// once, so a slow writer cannot pile up goroutines without bound. What
+	// a line does when the bound is reached is set by BackpressurePolicy,
+	// and WriteStats counts the lines held back. Fatal and Panic lines
+	// always wait.
+	MaxConcurrentWrites int
+
+	// BackpressurePolicy blocks by default, see MaxConcurrentWrites.
+	BackpressurePolicy BackpressurePolicy
+
+	// BackpressureTimeout is the wait of TimeoutWhenFull.
+	BackpressureTimeout time.Duration
+
+	// WriteErrorPolicy tells what happens to a line its writer failed to
+	// write, reported on stderr by default. See also SetWriteErrorHandler.
+	WriteErrorPolicy WriteErrorPolicy
+
+	// OnEntry is called with every entry once it is formatted, with the
+	// fields as they were handed to the formatter.
+	OnEntry func(entry Entry)
+
+	// RedactionRules mask fields by key on top of the `log:"redact"`
+	// struct tags, fully or keeping the last characters. On the request
+	// and response lines they also mask the JSON body entries, the headers
+	// and the query parameters of that name, headers whatever their case,
+	// e.g. password, token or card_number. They follow
+	// RedactionEnvironments and SetRedactionEnabled like the tags.
+	RedactionRules []RedactionRule
+
+	// RedactionEnvironments restricts redaction to the deployment
+	// environments listed, matched against Env, itself the APP_ENV
+	// environment variable when unset, e.g. []string{"production"} keeps
+	// full values in staging.
+	// Redaction is always on when empty. SetRedactionEnabled overrides it.
+	RedactionEnvironments []string
+
+	// EnableTokenClaims lets LogTokenClaims log the claims of JWT tokens at
+	// Debug. Leave it off outside of authentication debugging.
+	EnableTokenClaims bool
+
+	// SensitiveClaims lists the claims LogTokenClaims redacts, e.g. "sub".
+	SensitiveClaims []string
+
+	// LogRedactedKeys adds a "_redacted_keys" field listing, sorted, the
+	// names of the fields and nested keys a line had redacted, so audits
+	// can see redaction at work. Lines with nothing redacted get none.
+	LogRedactedKeys bool
+
+	// LogInitialization writes one Info line summarizing the effective
+	// configuration once the logger is built.
+	LogInitialization bool
+
+	// HTTP tunes Middleware, LogRequest and LogResponse.
+	HTTP HTTPConfig
+}
+
+// HTTPConfig holds the settings of the HTTP request and response logging.
+type HTTPConfig struct {
+	// ContextIdTrailer names an HTTP trailer carrying the context id, for
+	// streaming and gRPC-over-HTTP peers that correlate through trailers.
+	// The id of a request is taken from this trailer when the client
+	// declared it, which makes the middleware buffer the request body since
+	// trailers arrive after it, up to MaxBodyBytes or 1 MiB when unset; the
+	// trailer of a longer body is ignored. The response declares the trailer before
+	// the handler runs and sets it once the handler returns; clients only
+	// see it on chunked HTTP/1.1 or HTTP/2 responses, and a handler must
+	// not remove the pre-declared "Trailer" header.
+	ContextIdTrailer string
+
+	// RequestIdHeader names a request header carrying the context id, e.g.
+	// "X-Request-ID". The id of a request is taken from this header when
+	// present, ContextIdTrailer winning over it, and a new one generated
+	// otherwise; either way the response echoes it in the same header,
+	// set before the handler runs. Values longer than 128 bytes or holding
+	// other than visible ASCII are ignored so a client cannot forge lines.
+	RequestIdHeader string
+
+	// AccessLogStyle selects the lines Middleware writes per request, a
+	// "Request Body" and a "Response Body" line by default.
+	AccessLogStyle AccessLogStyle
+
+	// LogClockJumps adds a "clock_jump_ms" field to the "request completed"
+	// line of AccessLogPair and AccessLogSummary when the wall clock moved
+	// by more or less than the request duration, e.g. after an NTP step.
+	// duration_ms itself is always measured on the monotonic clock.
+	LogClockJumps bool
+
+	// LogHandlerName adds the name of the handler serving the request as a
+	// "handler" field: the name given by NamedHandler, the function name of
+	// an http.HandlerFunc or the type of any other handler.
+	LogHandlerName bool
+
+	// RequestLogOptions selects the attributes of the request line, when
+	// set it replaces LogUserAgent. DefaultRequestLogOptions when nil.
+	RequestLogOptions *RequestLogOptions
+
+	// ResponseHeaders adds "response_headers" to the response line with
+	// the values of these headers as the handler set them, so no header
+	// is logged unless listed here. Set-Cookie values are redacted.
+	ResponseHeaders []string
+
+	// CorrelationHash adds a "correlation_hash" field to every line of a
+	// request, a keyed hash of the request attributes it selects. Nil,
+	// the default, adds none.
+	CorrelationHash *CorrelationHash
+
+	// LogUserAgent adds the "user_agent" and "referer" request headers to
+	// the request line, each omitted when absent. User agents are cut to
+	// 512 bytes.
+	LogUserAgent bool
+
+	// BodySampleRate logs the request and response bodies of only this
+	// fraction of the requests served by Middleware, e.g. 0.05; the other
+	// requests still log their lines without bodies. The decision is made
+	// once per request. Zero, or one and above, logs every body.
+	BodySampleRate float64
+
+	// MaxBodyBytes, when positive, logs at most that many bytes of each
+	// request and response body, cut on a rune boundary, with a
+	// "request_truncated" or "response_truncated" field set to true and
+	// the full length in "request_length" or "response_length". Only that
+	// much of the request body is held in memory, the handler still reads
+	// it whole. A cut body is redacted whole while there are
+	// RedactionRules, it cannot be parsed to mask its entries.
+	MaxBodyBytes int
+
+	// Base64Bodies logs request and response bodies that are not valid
+	// UTF-8 as standard base64 under "request_b64" and "response_b64",
+	// with a "body_encoding" field set to "base64", instead of mangling
+	// them in the "request" and "response" text fields.
+	Base64Bodies bool
+
+	// LevelHeader names a request header, e.g. "X-Log-Level", asking for
+	// the lines of that request alone to be written down to the given
+	// level, e.g. debug. It can only make a request more verbose than the
+	// logger. The header is honoured only when AuthorizeLevelHeader
+	// approves the request, never when it is nil. Setting it keeps the
+	// logrus logger of GetEntry at TraceLevel.
+	LevelHeader string
+
+	// AuthorizeLevelHeader decides whether a request may use LevelHeader,
+	// e.g. by checking a token or the client address.
+	AuthorizeLevelHeader func(r *http.Request) bool
+
+	// LogTraceSampled adds a "sampled" boolean to the request and response
+	// lines, set from the trace flags of the OpenTelemetry span context of
+	// the request, so it shows why some requests have a trace and others
+	// not. Requests without a span context get no field.
+	LogTraceSampled bool
+}
+
+// callerLevel resolves the least severe level with caller fields
+func (c *Config) callerLevel() log.Level {
+	switch {
+	case c.ReportCaller:
+		return log.TraceLevel
+	case c.CallerLevel == log.PanicLevel:
+		return log.ErrorLevel
+	}
+
+	return c.CallerLevel
+}
+
+// validate reports the first setting of c that cannot work
+func (c *Config) validate() error {
+	if c.MaxMessageLength < 0 {
+		return fmt.Errorf("log: Config.MaxMessageLength is negative: %d", c.MaxMessageLength)
+	}
+	if c.MaxCollectionSize < 0 {
+		return fmt.Errorf("log: Config.MaxCollectionSize is negative: %d", c.MaxCollectionSize)
+	}
+	if c.AsyncBuffer < 0 {
+		return fmt.Errorf("log: Config.AsyncBuffer is negative: %d", c.AsyncBuffer)
+	}
+	if c.MaxConcurrentWrites < 0 {
+		return fmt.Errorf("log: Config.MaxConcurrentWrites is negative: %d", c.MaxConcurrentWrites)
+	}
+	if c.MaxConcurrentWrites > 0 && c.BackpressurePolicy == TimeoutWhenFull && c.BackpressureTimeout <= 0 {
+		return fmt.Errorf("log: Config.BackpressureTimeout must be positive with TimeoutWhenFull: %v", c.BackpressureTimeout)
+	}
+	if c.FlattenDepth < 0 {
+		return fmt.Errorf("log: Config.FlattenDepth is negative: %d", c.FlattenDepth)
+	}
+	for i, output := range c.Outputs {
+		if output.Writer == nil {
+			return fmt.Errorf("log: Config.Outputs[%d].Writer is nil", i)
+		}
+	}
+	if rate := c.ContextSampleRate; math.IsNaN(rate) || rate < 0 {
+		return fmt.Errorf("log: Config.ContextSampleRate is not a fraction: %v", rate)
+	}
+	if c.HTTP.MaxBodyBytes < 0 {
+		return fmt.Errorf("log: Config.HTTP.MaxBodyBytes is negative: %d", c.HTTP.MaxBodyBytes)
+	}
+	if rate := c.HTTP.BodySampleRate; math.IsNaN(rate) || rate < 0 {
+		return fmt.Errorf("log: Config.HTTP.BodySampleRate is not a fraction: %v", rate)
+	}
+	if hash := c.HTTP.CorrelationHash; hash != nil && hash.Hash == nil && len(hash.Key) == 0 {
+		return fmt.Errorf("log: Config.HTTP.CorrelationHash needs a Key or a Hash")
+	}
+
+	return nil
+}
+
+// features lists the optional behaviours enabled in c
+func (c *Config) features() []string {
+	features := make([]string, 0)
+	if c.SplitCallerLine {
+		features = append(features, "split_caller_line")
+	}
+	if c.ReportCaller {
+		features = append(features, "report_caller")
+	}
+	if c.CallerLevel != log.PanicLevel {
+		features = append(features, "caller_level")
+	}
+	if c.StringifyFields {
+		features = append(features, "stringify_fields")
+	}
+	if c.MaxCollectionSize > 0 {
+		features = append(features, "max_collection_size")
+	}
+	if c.OmitEmpty != KeepEmpty {
+		features = append(features, "omit_empty")
+	}
+	if c.FlattenSeparator != "" {
+		features = append(features, "flatten")
+	}
+	if c.ErrorContextTemplate != "" {
+		features = append(features, "error_context_template")
+	}
+	if c.Sequence {
+		features = append(features, "sequence")
+	}
+	if c.MaxMessageLength > 0 {
+		features = append(features, "max_message_length")
+	}
+	if c.FieldOrder != nil {
+		features = append(features, "field_order")
+	}
+	if c.StrictContext {
+		features = append(features, "strict_context")
+	}
+	if c.LogContextError {
+		features = append(features, "log_context_error")
+	}
+	if c.LogDeadline {
+		features = append(features, "log_deadline")
+	}
+	if len(c.RedactionRules) > 0 {
+		features = append(features, "redaction_rules")
+	}
+	if len(c.RedactionEnvironments) > 0 {
+		features = append(features, "redaction_environments")
+	}
+	if c.EnableTokenClaims {
+		features = append(features, "token_claims")
+	}
+	if c.LogRedactedKeys {
+		features = append(features, "log_redacted_keys")
+	}
+	if c.ContextSampleRate > 0 && c.ContextSampleRate < 1 {
+		features = append(features, "context_sample_rate")
+	}
+	if c.UnsafeSampleErrors {
+		features = append(features, "unsafe_sample_errors")
+	}
+	if c.DryRun {
+		features = append(features, "dry_run")
+	}
+	if c.AsyncBuffer > 0 {
+		features = append(features, "async")
+	}
+	if c.MaxConcurrentWrites > 0 {
+		features = append(features, "max_concurrent_writes")
+	}
+	if c.WriteErrorPolicy != ReportWriteErrors {
+		features = append(features, "write_error_policy")
+	}
+	if c.OnEntry != nil {
+		features = append(features, "on_entry")
+	}
+	if len(c.LevelFormatters) > 0 {
+		features = append(features, "level_formatters")
+	}
+	if c.LengthPrefixed {
+		features = append(features, "length_prefixed")
+	}
+	if len(c.Outputs) > 0 {
+		features = append(features, "multiple_outputs")
+	}
+	if c.HTTP.ContextIdTrailer != "" {
+		features = append(features, "context_id_trailer")
+	}
+	if c.HTTP.RequestIdHeader != "" {
+		features = append(features, "request_id_header")
+	}
+	if c.HTTP.AccessLogStyle != AccessLogBodies {
+		features = append(features, "access_log_style")
+	}
+	if c.HTTP.LogClockJumps {
+		features = append(features, "log_clock_jumps")
+	}
+	if c.HTTP.LogHandlerName {
+		features = append(features, "log_handler_name")
+	}
+	if c.HTTP.RequestLogOptions != nil {
+		features = append(features, "request_log_options")
+	}
+	if len(c.HTTP.ResponseHeaders) > 0 {
+		features = append(features, "response_headers")
+	}
+	if c.HTTP.CorrelationHash != nil {
+		features = append(features, "correlation_hash")
+	}
+	if c.HTTP.LogUserAgent {
+		features = append(features, "log_user_agent")
+	}
+	if c.HTTP.BodySampleRate > 0 && c.HTTP.BodySampleRate < 1 {
+		features = append(features, "body_sample_rate")
+	}
+	if c.HTTP.MaxBodyBytes > 0 {
+		features = append(features, "max_body_bytes")
+	}
+	if c.HTTP.Base64Bodies {
+		features = append(features, "base64_bodies")
+	}
+	if c.HTTP.LevelHeader != "" {
+		features = append(features, "level_header")
+	}
+	if c.HTTP.LogTraceSampled {
+		features = append(features, "log_trace_sampled")
+	}
+
+	return features
+}
+
+// ConfigSnapshot is the effective configuration of a logger, as reported
+// by Config. It holds names and types only, never a configured value such
+// as a redaction key or a PII pattern.
+type ConfigSnapshot struct {
+	// Level is the level of the logger, of its category for a Named logger
+	Level string `json:"log_level"`
+
+	// Formatter and Output are the types of the formatter and of the
+	// writer the logger was built with
+	Formatter string `json:"formatter"`
+	Output    string `json:"output"`
+
+	ReportCaller bool `json:"report_caller"`
+
+	// Features lists the optional behaviours enabled in the Config
+	Features []string `json:"features"`
+
+	// Redaction and PIIScrubbing tell whether SetRedactionEnabled and
+	// SetPIIScrubbing are on, PIIPatterns names the patterns scrubbed
+	Redaction    bool     `json:"redaction"`
+	PIIScrubbing bool     `json:"pii_scrubbing"`
+	PIIPatterns  []string `json:"pii_patterns"`
+}
+
+// Config reports how the logger is configured right now, e.g. for an admin
+// endpoint answering why debug lines are missing. It is safe to call while
+// logging and the snapshot shares nothing with the logger.
+func (l *Log) Config() ConfigSnapshot {
+	logger := l.entry.Logger
+
+	l.pii.mu.RLock()
+	patterns := make([]string, len(l.pii.patterns))
+	for i, pattern := range l.pii.patterns {
+		patterns[i] = pattern.Name
+	}
+	l.pii.mu.RUnlock()
+
+	return ConfigSnapshot{
+		Level:        l.levels.get(l.category).String(),
+		Formatter:    formatterName(logger.Formatter),
+		Output:       fmt.Sprintf("%T", unwrapWriter(logger.Out)),
+		ReportCaller: l.config.ReportCaller,
+		Features:     l.config.features(),
+		Redaction:    l.redaction.Load(),
+		PIIScrubbing: l.pii.enabled.Load(),
+		PIIPatterns:  patterns,
+	}
+}
+
+// logInitialization writes the configuration summary, only names and types
+// are reported so no configured value can leak into the log
+func (l *Log) logInitialization() {
+	snapshot := l.Config()
+
+	l.InfoMap(context.Background(), map[string]interface{}{
+		"log_level": snapshot.Level,
+		"formatter": snapshot.Formatter,
+		"output":    snapshot.Output,
+		"features":  snapshot.Features,
+	}, "logger initialized")
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// NewChildContext derives a context for a sub-operation of parent, e.g. one
+// branch of a fan-out. The child keeps the data map values of parent, gets
+// a fresh context id and records the id of parent as parent_context_id so
+// its lines can be traced back to the originating request.
+func NewChildContext(parent context.Context) context.Context {
+	data := copyDataMap(parent)
+
+	if parentId, ok := data[ContextIdKey]; ok {
+		data[ParentContextIdKey] = parentId
+	} else {
+		delete(data, ParentContextIdKey)
+	}
+	data[ContextIdKey] = newContextId()
+
+	return withSteps(context.WithValue(parent, ContextDataMapKey, data))
+}
+
+// WithTrace returns a copy of ctx whose lines carry the given trace_id and
+// span_id, for tracing systems that do not propagate an OpenTelemetry span
+// context. An empty id is left out. Tracer takes its ids from the span
+// context instead, which wins over these on the lines it writes.
+func WithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	data := copyDataMap(ctx)
+
+	for key, value := range map[string]string{TraceIdKey: traceID, SpanIdKey: spanID} {
+		if value == "" {
+			delete(data, key)
+			continue
+		}
+		data[key] = value
+	}
+
+	return context.WithValue(ctx, ContextDataMapKey, data)
+}
+
+// TraceFromContext returns the trace and span ids stored by WithTrace, empty
+// when absent.
+func TraceFromContext(ctx context.Context) (traceID, spanID string) {
+	traceValue, _ := ContextValue(ctx, TraceIdKey)
+	spanValue, _ := ContextValue(ctx, SpanIdKey)
+	traceID, _ = traceValue.(string)
+	spanID, _ = spanValue.(string)
+	return traceID, spanID
+}
+
+// FieldsFromContext returns a copy of the fields stored in the data map of
+// ctx, the context id included, as they are added to every line. The map
+// is empty, never nil, when ctx stores nothing.
+func FieldsFromContext(ctx context.Context) log.Fields {
+	data := contextDataValues(ctx)
+
+	fields := make(log.Fields, len(data))
+	for key, value := range data {
+		fields[key] = value
+	}
+
+	return fields
+}
+
+// AppendContextValue returns a copy of ctx whose lines carry key with value,
+// which keeps its type, e.g. a numeric user id, a flag or a nested map:
+//
+//	ctx = log.AppendContextValue(ctx, "user_id", 42)
+//
+// The data map of ctx is copied, so ctx and the lines already written keep
+// theirs. key is ignored when it is context_id, set with
+// BuildContextDataAndSetValue and its siblings only.
+func AppendContextValue(ctx context.Context, key string, value interface{}) context.Context {
+	if key == ContextIdKey {
+		return ctx
+	}
+
+	data := copyDataMap(ctx)
+	data[key] = value
+
+	return context.WithValue(ctx, ContextDataMapKey, data)
+}
+
+// ContextValue returns the value stored under key in the data map of ctx,
+// whether it was added with AppendContextValue or is part of a
+// map[string]string stored directly under ContextDataMapKey.
+func ContextValue(ctx context.Context, key string) (interface{}, bool) {
+	switch data := ctx.Value(ContextDataMapKey).(type) {
+	case map[string]interface{}:
+		value, ok := data[key]
+		return value, ok
+	case map[string]string:
+		value, ok := data[key]
+		return value, ok
+	}
+
+	return nil, false
+}
+
+// copyDataMap returns a writable copy of the data map of ctx, empty when
+// there is none
+func copyDataMap(ctx context.Context) map[string]interface{} {
+	values := contextDataValues(ctx)
+
+	data := make(map[string]interface{}, len(values)+1)
+	for key, value := range values {
+		data[key] = value
+	}
+	return data
+}
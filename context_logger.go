@@ -0,0 +1,194 @@
+package log
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ContextLogger is a Logger bound to a single context so the context does
+// not have to be passed on every call.
+type ContextLogger interface {
+	Infof(message string, args ...interface{})
+	Errorf(message string, args ...interface{})
+	Warnf(message string, args ...interface{})
+	Debugf(message string, args ...interface{})
+	Fatalf(message string, args ...interface{})
+	Tracef(message string, args ...interface{})
+	Panicf(message string, args ...interface{})
+	Info(args ...interface{})
+	Error(args ...interface{})
+	Warn(args ...interface{})
+	Debug(args ...interface{})
+	Fatal(args ...interface{})
+	Trace(args ...interface{})
+	Panic(args ...interface{})
+}
+
+type contextLogger struct {
+	log *Log
+	ctx context.Context
+
+	// fields are added to every line of this logger
+	fields log.Fields
+
+	// span receives every written line as an event, nil when not tracing
+	span trace.Span
+}
+
+func (l *Log) WithContext(ctx context.Context) ContextLogger {
+	return &contextLogger{log: l, ctx: ctx}
+}
+
+// Tracer returns a ContextLogger bound to ctx that carries the trace_id and
+// span_id of the active span and mirrors every written line to that span as
+// an event. It falls back to WithContext when ctx holds no valid span.
+//
+// Mirroring costs one span event per line, with the message, level and fields
+// converted to string attributes, on top of the regular log write.
+func (l *Log) Tracer(ctx context.Context) ContextLogger {
+	span := trace.SpanFromContext(ctx)
+	spanContext := span.SpanContext()
+	if !spanContext.IsValid() {
+		return l.WithContext(ctx)
+	}
+
+	cl := &contextLogger{
+		log: l,
+		ctx: ctx,
+		fields: log.Fields{
+			TraceIdKey: spanContext.TraceID().String(),
+			SpanIdKey:  spanContext.SpanID().String(),
+		},
+	}
+	if span.IsRecording() {
+		cl.span = span
+	}
+
+	return cl
+}
+
+func (c *contextLogger) Infof(message string, args ...interface{}) {
+	c.outputf(log.InfoLevel, message, args...)
+}
+
+func (c *contextLogger) Errorf(message string, args ...interface{}) {
+	c.outputf(log.ErrorLevel, message, args...)
+}
+
+func (c *contextLogger) Warnf(message string, args ...interface{}) {
+	c.outputf(log.WarnLevel, message, args...)
+}
+
+func (c *contextLogger) Debugf(message string, args ...interface{}) {
+	c.outputf(log.DebugLevel, message, args...)
+}
+
+func (c *contextLogger) Fatalf(message string, args ...interface{}) {
+	c.outputf(log.FatalLevel, message, args...)
+}
+
+func (c *contextLogger) Tracef(message string, args ...interface{}) {
+	c.outputf(log.TraceLevel, message, args...)
+}
+
+// Panicf is the formatted version of Panic
+func (c *contextLogger) Panicf(message string, args ...interface{}) {
+	c.outputf(log.PanicLevel, message, args...)
+}
+
+func (c *contextLogger) Info(args ...interface{}) {
+	c.output(log.InfoLevel, args...)
+}
+
+func (c *contextLogger) Error(args ...interface{}) {
+	c.output(log.ErrorLevel, args...)
+}
+
+func (c *contextLogger) Warn(args ...interface{}) {
+	c.output(log.WarnLevel, args...)
+}
+
+func (c *contextLogger) Debug(args ...interface{}) {
+	c.output(log.DebugLevel, args...)
+}
+
+func (c *contextLogger) Fatal(args ...interface{}) {
+	c.output(log.FatalLevel, args...)
+}
+
+func (c *contextLogger) Trace(args ...interface{}) {
+	c.output(log.TraceLevel, args...)
+}
+
+// Panic writes args at Panic level then panics with the written
+// *logrus.Entry, as Log.Panic does
+func (c *contextLogger) Panic(args ...interface{}) {
+	c.output(log.PanicLevel, args...)
+}
+
+func (c *contextLogger) output(level log.Level, args ...interface{}) {
+	if !c.log.enabled(c.ctx, level) {
+		return
+	}
+
+	c.emit(level, fmt.Sprint(args...), args)
+}
+
+func (c *contextLogger) outputf(level log.Level, message string, args ...interface{}) {
+	if !c.log.enabled(c.ctx, level) {
+		return
+	}
+
+	c.emit(level, fmt.Sprintf(message, args...), args)
+}
+
+func (c *contextLogger) emit(level log.Level, message string, args []interface{}) {
+	lp := c.log.newLogParams(c.ctx, level)
+	for key, value := range c.fields {
+		lp.fields[key] = value
+	}
+	lp.injectErrorFields(args).injectErrorContext(level, args)
+
+	if c.span != nil {
+		lp.mirror = c.addSpanEvent
+	}
+
+	c.log.emit(level, lp, message)
+}
+
+func (c *contextLogger) addSpanEvent(level log.Level, message string, fields log.Fields) {
+	attrs := make([]attribute.KeyValue, 0, len(fields)+2)
+	attrs = append(attrs,
+		attribute.String("log.severity", level.String()),
+		attribute.String("log.message", message),
+	)
+	for key, value := range fields {
+		attrs = append(attrs, spanAttribute(key, value))
+	}
+
+	c.span.AddEvent("log", trace.WithAttributes(attrs...))
+}
+
+// spanAttribute converts a field to an attribute, slices of basic types stay
+// slices and other composite values are JSON encoded rather than printed
+// the Go way
+func spanAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case []string:
+		return attribute.StringSlice(key, v)
+	case []int:
+		return attribute.IntSlice(key, v)
+	case []int64:
+		return attribute.Int64Slice(key, v)
+	case []float64:
+		return attribute.Float64Slice(key, v)
+	case []bool:
+		return attribute.BoolSlice(key, v)
+	}
+
+	return attribute.String(key, stringifyValue(value))
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	"github.com/sirupsen/logrus"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type recordingSpan struct {
+	trace.Span
+	spanContext trace.SpanContext
+	events      []string
+
+	// attributes holds the attributes of the last event
+	attributes map[string]string
+}
+
+func (s *recordingSpan) SpanContext() trace.SpanContext { return s.spanContext }
+func (s *recordingSpan) IsRecording() bool              { return true }
+func (s *recordingSpan) AddEvent(name string, options ...trace.EventOption) {
+	s.events = append(s.events, name)
+	s.attributes = make(map[string]string)
+	config := trace.NewEventConfig(options...)
+	for _, attr := range config.Attributes() {
+		s.attributes[string(attr.Key)] = attr.Value.Emit()
+	}
+}
+
+func TestTracerMirrorsToActiveSpan(t *testing.T) {
+	tracedLogger, hook := NewLoggerWithTestHook(sampleString)
+	tracedLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	span := &recordingSpan{spanContext: trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})}
+	ctx := trace.ContextWithSpan(sampleContext, span)
+
+	tracedLogger.Tracer(ctx).Info(sampleString)
+
+	entry := hook.LastEntry()
+	assert.Equal(t, span.spanContext.TraceID().String(), entry.Data[TraceIdKey])
+	assert.Equal(t, span.spanContext.SpanID().String(), entry.Data[SpanIdKey])
+	assert.Equal(t, "11", entry.Data[ContextIdKey])
+	assert.Equal(t, 1, len(span.events))
+}
+
+func TestTracerMirrorsTheWrittenLine(t *testing.T) {
+	tracedLogger := NewLoggerWithConfig(Config{Service: sampleString, RedactionRules: []RedactionRule{{Key: "card"}}})
+	tracedLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(tracedLogger.GetEntry().Logger)
+	tracedLogger.SetPIIScrubbing(true)
+
+	span := &recordingSpan{spanContext: trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})}
+	ctx := trace.ContextWithSpan(sampleContext, span)
+
+	err := WrapError(errors.New("signup a@b.co"), map[string]interface{}{"card": "4111111111111111", "email": "a@b.co"})
+	tracedLogger.Tracer(ctx).Error(err)
+
+	entry := hook.LastEntry()
+	assert.Equal(t, "signup "+RedactedValue, entry.Message)
+	assert.Equal(t, entry.Message, span.attributes["log.message"])
+	assert.Equal(t, RedactedValue, span.attributes["card"])
+	assert.Equal(t, RedactedValue, span.attributes["email"])
+}
+
+func TestTracerWithoutSpan(t *testing.T) {
+	tracedLogger, hook := NewLoggerWithTestHook(sampleString)
+	tracedLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	tracedLogger.Tracer(context.Background()).Info(sampleString)
+
+	entry := hook.LastEntry()
+	assert.Equal(t, sampleString, entry.Message)
+	_, ok := entry.Data[TraceIdKey]
+	assert.False(t, ok)
+}
+
+func TestSpanAttributeSlices(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, spanAttribute("tags", []string{"a", "b"}).Value.AsStringSlice())
+	assert.Equal(t, []int64{1, 2}, spanAttribute("ids", []int{1, 2}).Value.AsInt64Slice())
+	assert.Equal(t, `[{"sku":"x"}]`, spanAttribute("items", []struct {
+		SKU string `json:"sku"`
+	}{{SKU: "x"}}).Value.AsString())
+}
+
+func TestContextLoggerTraceAndPanic(t *testing.T) {
+	levelLogger, hook := NewLoggerWithTestHook(sampleString)
+	levelLogger.GetEntry().Logger.Out = ioutil.Discard
+	contextLogger := levelLogger.WithContext(sampleContext)
+
+	contextLogger.Tracef("frame %d", 1)
+	assert.Equal(t, 0, len(hook.AllEntries()))
+
+	levelLogger.SetLevel(logrus.TraceLevel)
+	contextLogger.Trace("frame")
+	assert.Equal(t, logrus.TraceLevel, hook.LastEntry().Level)
+	assert.Equal(t, "11", hook.LastEntry().Data[ContextIdKey])
+
+	func() {
+		defer func() {
+			// the written entry, not the message
+			_, ok := recover().(*logrus.Entry)
+			assert.True(t, ok)
+		}()
+		contextLogger.Panic("rethrown")
+	}()
+	assert.Equal(t, "rethrown", hook.LastEntry().Message)
+	assert.Equal(t, logrus.PanicLevel, hook.LastEntry().Level)
+
+	defer func() {
+		entry, ok := recover().(*logrus.Entry)
+		assert.True(t, ok)
+		assert.Equal(t, "rethrown 7", entry.Message)
+		assert.Equal(t, "11", hook.LastEntry().Data[ContextIdKey])
+	}()
+	contextLogger.Panicf("rethrown %d", 7)
+	t.Fatal("Panicf did not panic")
+}
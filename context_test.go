@@ -0,0 +1,89 @@
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+)
+
+func TestNewChildContext(t *testing.T) {
+	child := NewChildContext(requestWithContext.Context())
+	data := contextDataMap(child)
+
+	assert.Equal(t, "12", data[ParentContextIdKey])
+	assert.NotEqual(t, "12", data[ContextIdKey])
+	assert.Equal(t, "language_code", data["language"])
+
+	// the parent keeps its own id
+	assert.Equal(t, "12", contextDataMap(requestWithContext.Context())[ContextIdKey])
+
+	grandChild := NewChildContext(child)
+	assert.Equal(t, data[ContextIdKey], contextDataMap(grandChild)[ParentContextIdKey])
+}
+
+func TestFieldsFromContext(t *testing.T) {
+	fields := FieldsFromContext(requestWithContext.Context())
+	assert.Equal(t, "12", fields[ContextIdKey])
+	assert.Equal(t, "language_code", fields["language"])
+
+	// the copy does not write through to the context
+	fields["language"] = "changed"
+	assert.Equal(t, "language_code", contextDataMap(requestWithContext.Context())["language"])
+
+	empty := FieldsFromContext(context.Background())
+	assert.NotNil(t, empty)
+	assert.Equal(t, 0, len(empty))
+}
+
+func TestWithTrace(t *testing.T) {
+	traceLogger, hook := NewLoggerWithTestHook(sampleString)
+	traceLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	ctx := WithTrace(sampleContext, "4bf92f3577b34da6", "00f067aa0ba902b7")
+	traceLogger.Info(ctx, sampleString)
+
+	data := hook.LastEntry().Data
+	assert.Equal(t, "11", data[ContextIdKey])
+	assert.Equal(t, "4bf92f3577b34da6", data[TraceIdKey])
+	assert.Equal(t, "00f067aa0ba902b7", data[SpanIdKey])
+
+	traceID, spanID := TraceFromContext(WithTrace(ctx, "4bf92f3577b34da6", ""))
+	assert.Equal(t, "4bf92f3577b34da6", traceID)
+	assert.Equal(t, "", spanID)
+
+	// the context given is left untouched
+	traceID, _ = TraceFromContext(sampleContext)
+	assert.Equal(t, "", traceID)
+}
+
+func TestAppendContextValue(t *testing.T) {
+	valueLogger, hook := NewLoggerWithTestHook(sampleString)
+	valueLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	ctx := AppendContextValue(sampleContext, "user_id", 42)
+	ctx = AppendContextValue(ctx, "profile", map[string]interface{}{"admin": true})
+	ctx = AppendContextValue(ctx, ContextIdKey, "ignored")
+	valueLogger.Info(ctx, sampleString)
+
+	data := hook.LastEntry().Data
+	assert.Equal(t, "11", data[ContextIdKey])
+	assert.Equal(t, 42, data["user_id"])
+	assert.Equal(t, map[string]interface{}{"admin": true}, data["profile"])
+
+	// the context given is left untouched
+	_, ok := ContextValue(sampleContext, "user_id")
+	assert.False(t, ok)
+
+	// a map[string]string stored directly is still read
+	legacy := context.WithValue(context.Background(), ContextDataMapKey, map[string]string{ContextIdKey: "13"})
+	valueLogger.Info(AppendContextValue(legacy, "retry", true), sampleString)
+	data = hook.LastEntry().Data
+	assert.Equal(t, "13", data[ContextIdKey])
+	assert.Equal(t, true, data["retry"])
+
+	value, ok := ContextValue(legacy, ContextIdKey)
+	assert.True(t, ok)
+	assert.Equal(t, "13", value)
+}
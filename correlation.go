@@ -0,0 +1,73 @@
+package log
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// CorrelationHash configures the "correlation_hash" field Middleware adds to
+// every line of a request, to group the requests of a same client or
+// session without logging what identifies it. Only enable it where the
+// privacy policy allows such grouping.
+type CorrelationHash struct {
+	// Attributes returns the request attributes hashed, e.g.
+	// ClientAttributes or CookieAttributes("session"). A request for which
+	// it returns nothing gets no field.
+	Attributes func(r *http.Request) []string
+
+	// Key is the HMAC-SHA256 key of the default hash, so hashes of known
+	// inputs cannot be precomputed. Keep it secret and stable. It is
+	// required unless Hash is set.
+	Key []byte
+
+	// Hash replaces the default hash, the first 16 bytes of the
+	// HMAC-SHA256 of the attributes, hex encoded.
+	Hash func(attributes []string) string
+}
+
+// ClientAttributes are the client IP address, without port, and user agent
+func ClientAttributes(r *http.Request) []string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return []string{host, r.UserAgent()}
+}
+
+// CookieAttributes returns attributes made of the value of the named cookie
+func CookieAttributes(name string) func(r *http.Request) []string {
+	return func(r *http.Request) []string {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return nil
+		}
+
+		return []string{cookie.Value}
+	}
+}
+
+// hash returns the correlation hash of r, empty without attributes
+func (c *CorrelationHash) hash(r *http.Request) string {
+	if c.Attributes == nil {
+		return ""
+	}
+
+	attributes := c.Attributes(r)
+	if len(attributes) == 0 {
+		return ""
+	}
+	if c.Hash != nil {
+		return c.Hash(attributes)
+	}
+
+	// the separator cannot appear in header values, so distinct
+	// attribute lists never hash the same input
+	mac := hmac.New(sha256.New, c.Key)
+	mac.Write([]byte(strings.Join(attributes, "\n")))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,53 @@
+package log
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestMiddlewareCorrelationHash(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{
+		CorrelationHash: &CorrelationHash{Attributes: ClientAttributes, Key: []byte("key")},
+	}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(remoteAddr, userAgent string) string {
+		hook.Reset()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("User-Agent", userAgent)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+
+		entries := hook.AllEntries()
+		assert.Equal(t, entries[0].Data[CorrelationHashKey], entries[1].Data[CorrelationHashKey])
+		hash, _ := entries[0].Data[CorrelationHashKey].(string)
+		return hash
+	}
+
+	first := request("10.0.0.1:1234", "curl")
+	assert.Equal(t, 32, len(first))
+	assert.Equal(t, first, request("10.0.0.1:5678", "curl"))
+	assert.NotEqual(t, first, request("10.0.0.2:1234", "curl"))
+	assert.NotEqual(t, first, request("10.0.0.1:1234", "wget"))
+}
+
+func TestCorrelationHashAttributes(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	session := &CorrelationHash{Attributes: CookieAttributes("session")}
+	assert.Equal(t, "", session.hash(r))
+
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	custom := &CorrelationHash{
+		Attributes: CookieAttributes("session"),
+		Hash:       func(attributes []string) string { return "h:" + attributes[0] },
+	}
+	assert.Equal(t, "h:abc", custom.hash(r))
+	assert.NotEqual(t, "", session.hash(r))
+}
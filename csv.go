@@ -0,0 +1,92 @@
+package log
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultCSVColumns are the columns written by a CSVFormatter without Columns
+var DefaultCSVColumns = []string{"level", "time", "service", "context_id", "msg"}
+
+// CSVFormatter renders every entry as one CSV row, for pipelines that only
+// ingest CSV. Commas, quotes and newlines inside values are escaped the
+// RFC 4180 way.
+type CSVFormatter struct {
+	// Columns lists the keys written, in order. "time", "level" and "msg"
+	// are the entry timestamp, level and message. DefaultCSVColumns when nil.
+	Columns []string
+
+	// ExtraColumn appends one last column holding the fields that are not
+	// in Columns as a JSON object. They are dropped otherwise.
+	ExtraColumn bool
+
+	// TimestampFormat formats the "time" column, RFC3339Nano when empty
+	TimestampFormat string
+}
+
+func (f *CSVFormatter) Format(entry *log.Entry) ([]byte, error) {
+	columns := f.Columns
+	if columns == nil {
+		columns = DefaultCSVColumns
+	}
+
+	timestampFormat := f.TimestampFormat
+	if timestampFormat == "" {
+		timestampFormat = time.RFC3339Nano
+	}
+
+	record := make([]string, 0, len(columns)+1)
+	used := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		used[column] = true
+
+		switch column {
+		case "time":
+			record = append(record, entry.Time.Format(timestampFormat))
+		case "level":
+			record = append(record, entry.Level.String())
+		case "msg":
+			record = append(record, entry.Message)
+		default:
+			value, ok := entry.Data[column]
+			if !ok {
+				record = append(record, "")
+				continue
+			}
+			record = append(record, stringifyValue(value))
+		}
+	}
+
+	if f.ExtraColumn {
+		extra := make(map[string]interface{})
+		for key, value := range entry.Data {
+			if used[key] {
+				continue
+			}
+			if err, ok := value.(error); ok {
+				value = err.Error()
+			}
+			extra[key] = value
+		}
+
+		encoded, err := json.Marshal(extra)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal extra fields to JSON, %v", err)
+		}
+		record = append(record, string(encoded))
+	}
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	if err := w.Write(record); err != nil {
+		return nil, err
+	}
+	w.Flush()
+
+	return b.Bytes(), w.Error()
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"context"
+	"runtime"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maximumDumpBytes caps the goroutine dump, later goroutines are lost
+	maximumDumpBytes = 4 << 20
+
+	// dumpChunkBytes is the size of the stack of one dump line
+	dumpChunkBytes = 64 << 10
+)
+
+// LogGoroutineDump logs the stacks of every goroutine at Warn, e.g. from an
+// admin endpoint to diagnose a deadlock or a leak. The dump is split in
+// lines of 64 KiB numbered by "part" out of "parts", and cut at 4 MiB with
+// "truncated" set. It stops the world while collecting the stacks, so keep
+// it for rare, explicit use.
+func (l *Log) LogGoroutineDump(ctx context.Context) {
+	if !l.enabled(ctx, log.WarnLevel) {
+		return
+	}
+
+	dump, truncated := goroutineDump()
+	parts := (len(dump) + dumpChunkBytes - 1) / dumpChunkBytes
+	for part := 0; part < parts; part++ {
+		end := (part + 1) * dumpChunkBytes
+		if end > len(dump) {
+			end = len(dump)
+		}
+
+		lp := l.newLogParams(ctx, log.WarnLevel)
+		lp.fields[StackKey] = string(dump[part*dumpChunkBytes : end])
+		lp.fields[PartKey] = part + 1
+		lp.fields[PartsKey] = parts
+		lp.fields[TruncatedKey] = truncated
+		l.emit(log.WarnLevel, lp, "goroutine dump")
+	}
+}
+
+// goroutineDump returns the stacks of every goroutine, growing the buffer up
+// to maximumDumpBytes
+func goroutineDump() (dump []byte, truncated bool) {
+	size := dumpChunkBytes
+	for {
+		buf := make([]byte, size)
+		n := runtime.Stack(buf, true)
+		if n < size {
+			return buf[:n], false
+		}
+		if size >= maximumDumpBytes {
+			return buf[:n], true
+		}
+
+		size *= 4
+		if size > maximumDumpBytes {
+			size = maximumDumpBytes
+		}
+	}
+}
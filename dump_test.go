@@ -0,0 +1,26 @@
+package log
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestLogGoroutineDump(t *testing.T) {
+	dumpLogger, hook := NewLoggerWithTestHook(sampleString)
+	dumpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	dumpLogger.LogGoroutineDump(sampleContext)
+
+	entries := hook.AllEntries()
+	assert.True(t, len(entries) > 0)
+	first := entries[0]
+	assert.Equal(t, log.WarnLevel, first.Level)
+	assert.Equal(t, 1, first.Data[PartKey])
+	assert.Equal(t, len(entries), first.Data[PartsKey])
+	assert.Equal(t, false, first.Data[TruncatedKey])
+	assert.True(t, strings.Contains(first.Data[StackKey].(string), "TestLogGoroutineDump"))
+}
@@ -0,0 +1,31 @@
+package log
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Entry is one logged line as seen by Config.OnEntry and the Recorder,
+// without depending on the logging backend.
+type Entry struct {
+	Level   Level
+	Message string
+	Fields  map[string]interface{}
+	Time    time.Time
+}
+
+// newEntry copies entry, its fields included
+func newEntry(entry *log.Entry) Entry {
+	fields := make(map[string]interface{}, len(entry.Data))
+	for key, value := range entry.Data {
+		fields[key] = value
+	}
+
+	return Entry{
+		Level:   entry.Level,
+		Message: entry.Message,
+		Fields:  fields,
+		Time:    entry.Time,
+	}
+}
@@ -0,0 +1,61 @@
+package log
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// LevelEnvVariable holds the level of NewLoggerFromEnv, e.g. debug
+	LevelEnvVariable = "LOG_LEVEL"
+
+	// ReportCallerEnvVariable turns on Config.ReportCaller for
+	// NewLoggerFromEnv, e.g. true
+	ReportCallerEnvVariable = "LOG_REPORT_CALLER"
+)
+
+// NewLoggerFromEnv builds a logger for service configured by the
+// environment:
+//
+//	LOG_LEVEL          trace, debug, info, warn, error, fatal or panic; info when unset
+//	LOG_REPORT_CALLER  true to add the caller fields on every level; false when unset
+//
+// An unparsable value falls back to the default and is reported by a Warn
+// line once the logger is built.
+func NewLoggerFromEnv(service string) Logger {
+	var invalid []string
+
+	level := log.InfoLevel
+	if value := os.Getenv(LevelEnvVariable); value != "" {
+		parsed, err := log.ParseLevel(strings.TrimSpace(value))
+		if err == nil {
+			level = parsed
+		} else {
+			invalid = append(invalid, LevelEnvVariable)
+		}
+	}
+
+	cfg := Config{Service: service}
+	if value := os.Getenv(ReportCallerEnvVariable); value != "" {
+		reportCaller, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err == nil {
+			cfg.ReportCaller = reportCaller
+		} else {
+			invalid = append(invalid, ReportCallerEnvVariable)
+		}
+	}
+
+	logger := log.New()
+	logger.SetLevel(level)
+	l := newLog(logger, cfg)
+
+	for _, name := range invalid {
+		l.Warnf(context.Background(), "ignoring invalid %s=%q", name, os.Getenv(name))
+	}
+
+	return l
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestNewLoggerFromEnv(t *testing.T) {
+	t.Setenv(LevelEnvVariable, "debug")
+	t.Setenv(ReportCallerEnvVariable, "true")
+
+	envLogger := NewLoggerFromEnv(sampleString)
+	envLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(envLogger.GetEntry().Logger)
+
+	envLogger.Debug(context.Background(), sampleString)
+	entry := hook.LastEntry()
+	assert.NotNil(t, entry)
+	assert.Equal(t, log.DebugLevel, entry.Level)
+	_, ok := entry.Data[FuncKey]
+	assert.True(t, ok)
+}
+
+func TestNewLoggerFromEnvDefaults(t *testing.T) {
+	t.Setenv(LevelEnvVariable, "")
+	t.Setenv(ReportCallerEnvVariable, "")
+
+	envLogger := NewLoggerFromEnv(sampleString)
+	envLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(envLogger.GetEntry().Logger)
+
+	envLogger.Debug(context.Background(), sampleString)
+	assert.Equal(t, 0, len(hook.AllEntries()))
+
+	envLogger.Info(context.Background(), sampleString)
+	_, ok := hook.LastEntry().Data[FuncKey]
+	assert.False(t, ok)
+}
@@ -0,0 +1,103 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StructuredError wraps an error with fields describing where and how it
+// arose. Logging an error holding StructuredError layers in its chain, e.g.
+// with Error(ctx, err), adds the fields of every layer to the line.
+type StructuredError struct {
+	Err    error
+	Fields map[string]interface{}
+}
+
+// WrapError wraps err with fields, it returns nil when err is nil.
+func WrapError(err error, fields map[string]interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	return &StructuredError{Err: err, Fields: fields}
+}
+
+func (e *StructuredError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StructuredError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorFields collects the fields of the StructuredError layers found by
+// walking the chain of err with errors.Unwrap. When layers share a key, the
+// outermost layer wins: the code closest to the log call has the last word.
+func ErrorFields(err error) map[string]interface{} {
+	fields := make(map[string]interface{})
+	for ; err != nil; err = errors.Unwrap(err) {
+		structured, ok := err.(*StructuredError)
+		if !ok {
+			continue
+		}
+		for key, value := range structured.Fields {
+			if _, exists := fields[key]; !exists {
+				fields[key] = value
+			}
+		}
+	}
+
+	return fields
+}
+
+// injectErrorFields adds the fields of the errors among args, the fields
+// already set, context ones included, are kept
+func (lp *LogParams) injectErrorFields(args []interface{}) *LogParams {
+	for _, arg := range args {
+		err, ok := arg.(error)
+		if !ok {
+			continue
+		}
+		for key, value := range ErrorFields(err) {
+			if _, exists := lp.fields[key]; !exists {
+				lp.fields[key] = value
+			}
+		}
+	}
+
+	return lp
+}
+
+// DefaultErrorContextTemplate composes the "err_ctx" field from the caller
+// function, the context id and the type of the logged error, e.g.
+// "main.charge|0b6e…|*net.OpError".
+const DefaultErrorContextTemplate = "{func}|{context_id}|{error_type}"
+
+// injectErrorContext adds the "err_ctx" field composed by template to the
+// lines at Error and above, the type of the first error among args is used
+func (lp *LogParams) injectErrorContext(level log.Level, args []interface{}) *LogParams {
+	template := lp.config.ErrorContextTemplate
+	if template == "" || level > log.ErrorLevel {
+		return lp
+	}
+
+	errorType := ""
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			errorType = fmt.Sprintf("%T", err)
+			break
+		}
+	}
+
+	caller, _ := lp.fields[FuncKey].(string)
+	contextId, _ := lp.fields[ContextIdKey].(string)
+	lp.fields[ErrorContextKey] = strings.NewReplacer(
+		"{func}", caller,
+		"{context_id}", contextId,
+		"{error_type}", errorType,
+	).Replace(template)
+	return lp
+}
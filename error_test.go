@@ -0,0 +1,57 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestErrorFieldsChain(t *testing.T) {
+	inner := WrapError(errors.New("no rows"), map[string]interface{}{"table": "orders", "layer": "repository"})
+	middle := fmt.Errorf("load order: %w", inner)
+	outer := WrapError(middle, map[string]interface{}{"order_id": 7, "layer": "service"})
+
+	fields := ErrorFields(outer)
+	assert.Equal(t, "orders", fields["table"])
+	assert.Equal(t, 7, fields["order_id"])
+	assert.Equal(t, "service", fields["layer"])
+	assert.Equal(t, "load order: no rows", outer.Error())
+	assert.Nil(t, WrapError(nil, fields))
+}
+
+func TestLogMergesErrorFields(t *testing.T) {
+	errorLogger, hook := NewLoggerWithTestHook(sampleString)
+	errorLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	err := WrapError(errors.New("timeout"), map[string]interface{}{"attempt": 3, ContextIdKey: "ignored"})
+	errorLogger.Errorf(sampleContext, "charge failed: %v", err)
+
+	entry := hook.LastEntry()
+	assert.Equal(t, 3, entry.Data["attempt"])
+	assert.Equal(t, "11", entry.Data[ContextIdKey])
+
+	errorLogger.WithContext(sampleContext).Error(err)
+	assert.Equal(t, 3, hook.LastEntry().Data["attempt"])
+}
+
+type chargeError struct{}
+
+func (*chargeError) Error() string { return "declined" }
+
+func TestErrorContextTemplate(t *testing.T) {
+	errorLogger := NewLoggerWithConfig(Config{Service: sampleString, ErrorContextTemplate: DefaultErrorContextTemplate})
+	errorLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(errorLogger.GetEntry().Logger)
+
+	errorLogger.Error(sampleContext, "charge failed: ", &chargeError{})
+	entry := hook.LastEntry()
+	assert.Equal(t, fmt.Sprintf("%s|11|*log.chargeError", entry.Data[FuncKey]), entry.Data[ErrorContextKey])
+
+	errorLogger.Warn(sampleContext, &chargeError{})
+	_, ok := hook.LastEntry().Data[ErrorContextKey]
+	assert.False(t, ok)
+}
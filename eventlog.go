@@ -0,0 +1,28 @@
+package log
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// eventType is the type of a Windows Event Log event
+type eventType int
+
+const (
+	informationEvent eventType = iota
+	warningEvent
+	errorEvent
+)
+
+// eventTypeFor tells the type of the event a line of level is written as:
+// Warn lines are Warning events, Error and above Error events and the
+// others Information events
+func eventTypeFor(level log.Level) eventType {
+	switch {
+	case level <= log.ErrorLevel:
+		return errorEvent
+	case level == log.WarnLevel:
+		return warningEvent
+	default:
+		return informationEvent
+	}
+}
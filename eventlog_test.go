@@ -0,0 +1,23 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestEventTypeFor(t *testing.T) {
+	expected := map[log.Level]eventType{
+		log.PanicLevel: errorEvent,
+		log.FatalLevel: errorEvent,
+		log.ErrorLevel: errorEvent,
+		log.WarnLevel:  warningEvent,
+		log.InfoLevel:  informationEvent,
+		log.DebugLevel: informationEvent,
+		log.TraceLevel: informationEvent,
+	}
+	for _, level := range log.AllLevels {
+		assert.Equal(t, expected[level], eventTypeFor(level), level.String())
+	}
+}
@@ -0,0 +1,70 @@
+//go:build windows
+
+package log
+
+import (
+	"io/ioutil"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventLogId is the event id of every line written to the Windows Event Log
+const eventLogId = 1
+
+// NewEventLogLogger builds a logger for service writing to the Windows Event
+// Log with service as the event source, e.g. for services deployed without
+// a log collector. Each line is written as JSON, Warn lines as Warning
+// events, Error and above as Error events and the others as Information
+// events. The source must be registered first, e.g. by the installer with
+// eventlog.InstallAsEventCreate. Shutdown closes the event log.
+func NewEventLogLogger(service string) (Logger, error) {
+	events, err := eventlog.Open(service)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := log.New()
+	l := newLog(logger, Config{Service: service})
+	logger.AddHook(&eventLogHook{
+		events: events,
+		formatter: &fieldFormatter{
+			Formatter: &log.JSONFormatter{TimestampFormat: time.RFC3339Nano},
+			config:    l.config,
+		},
+	})
+	logger.SetOutput(ioutil.Discard)
+	l.OnShutdown(func() {
+		events.Close()
+	})
+
+	return l, nil
+}
+
+// eventLogHook writes every entry to the Windows Event Log
+type eventLogHook struct {
+	events    *eventlog.Log
+	formatter log.Formatter
+}
+
+func (h *eventLogHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *eventLogHook) Fire(entry *log.Entry) error {
+	serialized, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	message := string(serialized)
+	switch eventTypeFor(entry.Level) {
+	case errorEvent:
+		return h.events.Error(eventLogId, message)
+	case warningEvent:
+		return h.events.Warning(eventLogId, message)
+	default:
+		return h.events.Info(eventLogId, message)
+	}
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultFlattenDepth is the depth used when Config.FlattenDepth is zero
+const defaultFlattenDepth = 8
+
+// flattenFields unfolds the nested values of fields into keys joined with
+// separator, the fields of the entry win over flattened keys
+func flattenFields(fields log.Fields, separator string, depth int) log.Fields {
+	if depth <= 0 {
+		depth = defaultFlattenDepth
+	}
+
+	flattened := make(log.Fields, len(fields))
+	nested := make(map[string]map[string]interface{})
+	for key, value := range fields {
+		if m, ok := nestedValue(value); ok {
+			nested[key] = m
+			continue
+		}
+		flattened[key] = value
+	}
+
+	for key, m := range nested {
+		flattenInto(flattened, key, m, separator, depth)
+	}
+
+	return flattened
+}
+
+// flattenInto adds the leaves of m to fields under prefix, keeping the
+// values below depth levels nested
+func flattenInto(fields log.Fields, prefix string, m map[string]interface{}, separator string, depth int) {
+	if depth == 0 || len(m) == 0 {
+		if _, exists := fields[prefix]; !exists {
+			fields[prefix] = m
+		}
+		return
+	}
+
+	for key, value := range m {
+		key = prefix + separator + key
+		if child, ok := value.(map[string]interface{}); ok {
+			flattenInto(fields, key, child, separator, depth-1)
+			continue
+		}
+		if _, exists := fields[key]; !exists {
+			fields[key] = value
+		}
+	}
+}
+
+// nestedValue returns the JSON object form of a map or struct value. Going
+// through encoding/json honours the json tags and fails on cycles instead
+// of looping, in which case the value is left untouched.
+func nestedValue(value interface{}) (map[string]interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	if _, ok := value.(error); ok {
+		return nil, false
+	}
+	switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Map, reflect.Struct:
+	default:
+		return nil, false
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var m map[string]interface{}
+	if err := decoder.Decode(&m); err != nil || m == nil {
+		return nil, false
+	}
+
+	return m, true
+}
@@ -0,0 +1,204 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fieldFormatter applies the configured field processing to the merged
+// fields of an entry, then hands it to the wrapped formatter
+type fieldFormatter struct {
+	log.Formatter
+	config *Config
+
+	// notify calls Config.OnEntry, only set on the logger formatter so the
+	// callback runs once per entry whatever the number of outputs
+	notify bool
+}
+
+func (f *fieldFormatter) Format(entry *log.Entry) ([]byte, error) {
+	data := make(log.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		data[key] = summarizeCollection(serializeValue(value), f.config.MaxCollectionSize)
+	}
+	if f.config.FlattenSeparator != "" {
+		data = flattenFields(data, f.config.FlattenSeparator, f.config.FlattenDepth)
+	}
+	omitEmptyFields(data, f.config.OmitEmpty)
+	if f.config.StringifyFields {
+		for key, value := range data {
+			data[key] = stringifyValue(value)
+		}
+	}
+
+	formatted := *entry
+	formatted.Data = data
+	serialized, err := f.Formatter.Format(&formatted)
+	if err == nil && f.notify && f.config.OnEntry != nil {
+		f.config.OnEntry(newEntry(&formatted))
+	}
+
+	return serialized, err
+}
+
+// summarizeCollection replaces a slice, array or map of more than max
+// elements by its type and length, e.g. "[]main.User(len=10000)"
+func summarizeCollection(value interface{}, max int) interface{} {
+	if max <= 0 || value == nil {
+		return value
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if v.Len() > max {
+			return fmt.Sprintf("%s(len=%d)", v.Type(), v.Len())
+		}
+	}
+
+	return value
+}
+
+// stringifyValue renders value as a string, composite values are JSON encoded
+func stringifyValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case json.Marshaler:
+		if b, err := v.MarshalJSON(); err == nil {
+			return string(b)
+		}
+	}
+
+	switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		if b, err := json.Marshal(value); err == nil {
+			return string(b)
+		}
+	}
+
+	return fmt.Sprint(value)
+}
+
+// formatterName reports the type of the formatter configured by the user
+func formatterName(formatter log.Formatter) string {
+	if f, ok := formatter.(*fieldFormatter); ok {
+		formatter = f.Formatter
+	}
+
+	return fmt.Sprintf("%T", formatter)
+}
+
+// LevelFormatter renders each entry with the formatter of its level, e.g.
+// a verbose one for errors and a terse one for the rest. The choice is made
+// per entry under the logger lock, so lines keep their order and are never
+// interleaved whatever the formatters.
+type LevelFormatter struct {
+	// Levels maps a level to its formatter
+	Levels map[log.Level]log.Formatter
+
+	// Default renders the levels missing from Levels
+	Default log.Formatter
+}
+
+func (f *LevelFormatter) Format(entry *log.Entry) ([]byte, error) {
+	if formatter, ok := f.Levels[entry.Level]; ok && formatter != nil {
+		return formatter.Format(entry)
+	}
+
+	return f.Default.Format(entry)
+}
+
+// DefaultFieldOrder puts the keys most read by humans first
+var DefaultFieldOrder = []string{"time", "level", "service", "env", "context_id", "msg"}
+
+// JSONFormatter renders an entry as a JSON object like the logrus one, with
+// the keys in FieldOrder first and the remaining keys sorted alphabetically,
+// so lines are stable to read and to diff.
+type JSONFormatter struct {
+	// TimestampFormat formats the "time" key, RFC3339Nano when empty
+	TimestampFormat string
+
+	// FieldOrder lists the keys written first, DefaultFieldOrder when nil
+	FieldOrder []string
+}
+
+func (f *JSONFormatter) Format(entry *log.Entry) ([]byte, error) {
+	data := make(log.Fields, len(entry.Data)+3)
+	for key, value := range entry.Data {
+		switch key {
+		case "time", "msg", "level":
+			key = "fields." + key
+		}
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		data[key] = value
+	}
+
+	timestampFormat := f.TimestampFormat
+	if timestampFormat == "" {
+		timestampFormat = time.RFC3339Nano
+	}
+	data["time"] = entry.Time.Format(timestampFormat)
+	data["msg"] = entry.Message
+	data["level"] = entry.Level.String()
+
+	order := f.FieldOrder
+	if order == nil {
+		order = DefaultFieldOrder
+	}
+
+	keys := make([]string, 0, len(data))
+	written := make(map[string]bool, len(order))
+	for _, key := range order {
+		if _, ok := data[key]; ok && !written[key] {
+			keys = append(keys, key)
+			written[key] = true
+		}
+	}
+	rest := make([]string, 0, len(data)-len(keys))
+	for key := range data {
+		if !written[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	b := entry.Buffer
+	if b == nil {
+		b = &bytes.Buffer{}
+	}
+
+	b.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		encodedKey, _ := json.Marshal(key)
+		encodedValue, err := json.Marshal(data[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal field %q to JSON, %v", key, err)
+		}
+		b.Write(encodedKey)
+		b.WriteByte(':')
+		b.Write(encodedValue)
+	}
+	b.WriteString("}\n")
+
+	return b.Bytes(), nil
+}
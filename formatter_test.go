@@ -0,0 +1,229 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestStringifyFields(t *testing.T) {
+	var buf bytes.Buffer
+	stringLogger := NewLoggerWithConfig(Config{Service: sampleString, StringifyFields: true})
+	stringLogger.GetEntry().Logger.Out = &buf
+
+	stringLogger.InfoMap(sampleContext, map[string]interface{}{
+		"count":   10,
+		"enabled": true,
+		"tags":    []string{"a", "b"},
+	}, sampleString)
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "10", line["count"])
+	assert.Equal(t, "true", line["enabled"])
+	assert.Equal(t, `["a","b"]`, line["tags"])
+}
+
+func TestMultipleOutputs(t *testing.T) {
+	var textBuf, jsonBuf bytes.Buffer
+	multiLogger := NewLoggerWithConfig(Config{
+		Service: sampleString,
+		Outputs: []Output{
+			{Writer: &textBuf, Formatter: &log.TextFormatter{DisableColors: true}},
+			{Writer: &jsonBuf},
+		},
+	})
+
+	multiLogger.Info(sampleContext, "hello")
+
+	assert.True(t, strings.Contains(textBuf.String(), `msg=hello`))
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(jsonBuf.Bytes(), &line))
+	assert.Equal(t, "hello", line["msg"])
+	assert.Equal(t, "11", line[ContextIdKey])
+}
+
+func TestJSONFormatterFieldOrder(t *testing.T) {
+	var buf bytes.Buffer
+	orderedLogger := NewLoggerWithConfig(Config{Service: "svc", FieldOrder: DefaultFieldOrder})
+	orderedLogger.GetEntry().Logger.Out = &buf
+
+	orderedLogger.InfoMap(sampleContext, map[string]interface{}{"b": 2, "a": 1, "level": "x"}, "hello")
+
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, `{"time":`))
+	assert.True(t, strings.HasSuffix(line, `"level":"info","service":"svc","context_id":"11","msg":"hello","a":1,"b":2,"fields.level":"x"}`+"\n"))
+}
+
+func TestLevelFormatters(t *testing.T) {
+	var buf bytes.Buffer
+	levelLogger := NewLoggerWithConfig(Config{
+		Service: "svc",
+		LevelFormatters: map[log.Level]log.Formatter{
+			log.ErrorLevel: &log.TextFormatter{DisableTimestamp: true},
+		},
+	})
+	levelLogger.GetEntry().Logger.Out = &buf
+
+	levelLogger.Info(sampleContext, "hello")
+	levelLogger.Error(sampleContext, "failed")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "{"))
+	assert.True(t, strings.HasPrefix(lines[1], `level=error msg=failed`))
+}
+
+func TestMaxCollectionSize(t *testing.T) {
+	var entries []Entry
+	collectionLogger := NewLoggerWithConfig(Config{
+		Service:           sampleString,
+		DryRun:            true,
+		MaxCollectionSize: 3,
+		OnEntry: func(entry Entry) {
+			entries = append(entries, entry)
+		},
+	})
+
+	collectionLogger.InfoMap(sampleContext, map[string]interface{}{
+		"ids":   []int{1, 2, 3, 4},
+		"tags":  []string{"a", "b"},
+		"users": map[string]int{"a": 1, "b": 2, "c": 3, "d": 4},
+	})
+
+	fields := entries[0].Fields
+	assert.Equal(t, "[]int(len=4)", fields["ids"])
+	assert.Equal(t, []string{"a", "b"}, fields["tags"])
+	assert.Equal(t, "map[string]int(len=4)", fields["users"])
+}
+
+func TestDryRunCallsOnEntry(t *testing.T) {
+	var entries []Entry
+	dryLogger := NewLoggerWithConfig(Config{
+		Service:         sampleString,
+		DryRun:          true,
+		StringifyFields: true,
+		OnEntry: func(entry Entry) {
+			entries = append(entries, entry)
+		},
+	})
+
+	dryLogger.InfoMap(sampleContext, map[string]interface{}{"count": 1}, "hello")
+
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "hello", entries[0].Message)
+	assert.Equal(t, "1", entries[0].Fields["count"])
+}
+
+func TestLokiFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	lokiLogger := NewLoggerWithConfig(Config{
+		Service: "svc",
+		Outputs: []Output{{Writer: &buf, Formatter: &LokiFormatter{}}},
+	})
+
+	lokiLogger.Info(sampleContext, "hello")
+
+	var payload lokiPush
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &payload))
+	assert.Equal(t, map[string]string{"service": "svc", "level": "info"}, payload.Streams[0].Stream)
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(payload.Streams[0].Values[0][1]), &line))
+	assert.Equal(t, "hello", line["msg"])
+	assert.Equal(t, "11", line[ContextIdKey])
+	_, ok := line["service"]
+	assert.False(t, ok)
+}
+
+func TestCSVFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	csvLogger := NewLoggerWithConfig(Config{
+		Service: "svc",
+		Outputs: []Output{{Writer: &buf, Formatter: &CSVFormatter{
+			Columns:     []string{"level", "service", "context_id", "msg"},
+			ExtraColumn: true,
+		}}},
+	})
+
+	csvLogger.InfoMap(sampleContext, map[string]interface{}{"count": 1}, "hello, \"world\"\nbye")
+
+	assert.Equal(t, "info,svc,11,\"hello, \"\"world\"\"\nbye\",\"{\"\"count\"\":1}\"\n", buf.String())
+}
+
+func TestFlattenFields(t *testing.T) {
+	type user struct {
+		ID      int               `json:"id"`
+		Name    string            `json:"name"`
+		Roles   []string          `json:"roles"`
+		Profile map[string]string `json:"profile"`
+	}
+
+	var buffer bytes.Buffer
+	flatLogger := NewLoggerWithConfig(Config{Service: sampleString, FlattenSeparator: ".", FlattenDepth: 1})
+	flatLogger.GetEntry().Logger.Out = &buffer
+
+	flatLogger.InfoMap(context.Background(), map[string]interface{}{
+		"user":    user{ID: 7, Name: "ana", Roles: []string{"admin"}, Profile: map[string]string{"tz": "UTC"}},
+		"user.id": "kept",
+	}, sampleString)
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &line))
+	assert.Equal(t, "kept", line["user.id"])
+	assert.Equal(t, "ana", line["user.name"])
+	assert.Equal(t, []interface{}{"admin"}, line["user.roles"])
+	assert.Equal(t, map[string]interface{}{"tz": "UTC"}, line["user.profile"])
+	_, ok := line["user"]
+	assert.False(t, ok)
+}
+
+func TestFlattenFieldsCycle(t *testing.T) {
+	type node struct {
+		Next *node `json:"next"`
+	}
+	cycle := &node{}
+	cycle.Next = cycle
+
+	fields := flattenFields(log.Fields{"node": cycle}, ".", 0)
+	assert.Equal(t, cycle, fields["node"])
+}
+
+func TestSliceFieldsAreJSONArrays(t *testing.T) {
+	type item struct {
+		SKU string `json:"sku"`
+		Qty int    `json:"qty"`
+	}
+	fields := map[string]interface{}{
+		"tags":  []string{"a", "b"},
+		"ids":   []int{1, 2},
+		"items": []item{{SKU: "x", Qty: 1}},
+		"pair":  [2]string{"k", "v"},
+	}
+	expected := map[string]interface{}{
+		"tags":  []interface{}{"a", "b"},
+		"ids":   []interface{}{float64(1), float64(2)},
+		"items": []interface{}{map[string]interface{}{"sku": "x", "qty": float64(1)}},
+		"pair":  []interface{}{"k", "v"},
+	}
+
+	for _, cfg := range []Config{{Service: sampleString}, {Service: sampleString, FieldOrder: DefaultFieldOrder}} {
+		var buf bytes.Buffer
+		sliceLogger := NewLoggerWithConfig(cfg)
+		sliceLogger.GetEntry().Logger.Out = &buf
+
+		sliceLogger.InfoMap(sampleContext, fields, sampleString)
+
+		var line map[string]interface{}
+		assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+		for key, value := range expected {
+			assert.Equal(t, value, line[key])
+		}
+	}
+}
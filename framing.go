@@ -0,0 +1,59 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// frameHeaderSize is the size of the big-endian length before each record
+const frameHeaderSize = 4
+
+// framedWriter writes every line as one frame: its length as 4 big-endian
+// bytes, then the record without its trailing newline. A write holding
+// several lines, e.g. a batch of Config.AsyncBuffer, is split in as many
+// frames; formatters escape the newlines inside a record.
+type framedWriter struct {
+	io.Writer
+}
+
+func (w *framedWriter) Write(p []byte) (int, error) {
+	var frames []byte
+	for _, record := range bytes.Split(bytes.TrimSuffix(p, []byte("\n")), []byte("\n")) {
+		if uint64(len(record)) > math.MaxUint32 {
+			return 0, fmt.Errorf("log: record of %d bytes is too large to frame", len(record))
+		}
+
+		var header [frameHeaderSize]byte
+		binary.BigEndian.PutUint32(header[:], uint32(len(record)))
+		frames = append(frames, header[:]...)
+		frames = append(frames, record...)
+	}
+	if _, err := w.Writer.Write(frames); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// ReadFrame reads one record written with Config.LengthPrefixed from r. It
+// returns io.EOF when r ends between records and io.ErrUnexpectedEOF when
+// it ends inside one.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	record := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(r, record); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return record, nil
+}
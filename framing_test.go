@@ -0,0 +1,66 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestLengthPrefixed(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	framedLogger := newLog(logger, Config{Service: sampleString, LengthPrefixed: true})
+
+	framedLogger.Info(sampleContext, "first\nline")
+	framedLogger.Info(sampleContext, "second")
+
+	r := bytes.NewReader(out.Bytes())
+	for _, message := range []string{"first\nline", "second"} {
+		record, err := ReadFrame(r)
+		assert.Nil(t, err)
+		assert.False(t, bytes.HasSuffix(record, []byte("\n")))
+
+		var line map[string]interface{}
+		assert.Nil(t, json.Unmarshal(record, &line))
+		assert.Equal(t, message, line["msg"])
+	}
+	_, err := ReadFrame(r)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = ReadFrame(bytes.NewReader([]byte{0, 0, 0, 9, '{'}))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestLengthPrefixedBatches(t *testing.T) {
+	var out lockedBuffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	framedLogger := newLog(logger, Config{Service: sampleString, LengthPrefixed: true, AsyncBuffer: 16})
+	framedLogger.SetBatch(4, time.Second)
+
+	messages := []string{"first\nline", "second", "third", "fourth"}
+	for _, message := range messages {
+		framedLogger.Info(sampleContext, message)
+	}
+	assert.Nil(t, framedLogger.Shutdown(context.Background()))
+
+	// one frame per line, not per batch
+	r := bytes.NewReader(out.Bytes())
+	for _, message := range messages {
+		record, err := ReadFrame(r)
+		assert.Nil(t, err)
+
+		var line map[string]interface{}
+		assert.Nil(t, json.Unmarshal(record, &line))
+		assert.Equal(t, message, line["msg"])
+	}
+	_, err := ReadFrame(r)
+	assert.Equal(t, io.EOF, err)
+}
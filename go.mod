@@ -1,10 +1,14 @@
 module github.com/muhammad-fakhri/log
 
-go 1.17
+go 1.21
 
 require (
 	github.com/c2fo/testify v0.0.0-20150827203832-fba96363964a
 	github.com/sirupsen/logrus v1.4.2
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+	golang.org/x/sys v0.0.0-20200323222414-85ca7c5b95cd
+	google.golang.org/protobuf v1.34.2
 )
 
-require golang.org/x/sys v0.0.0-20200323222414-85ca7c5b95cd // indirect
+require github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
@@ -0,0 +1,70 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Detach returns a context carrying the log values of ctx without its
+// cancellation or deadline, for work that outlives the request. The data map
+// is copied so the detached work never shares it with the request.
+func Detach(ctx context.Context) context.Context {
+	detached := context.WithoutCancel(ctx)
+
+	if contextDataValues(ctx) != nil {
+		detached = context.WithValue(detached, ContextDataMapKey, copyDataMap(ctx))
+	}
+
+	return detached
+}
+
+// Go runs fn in a new goroutine with a detached copy of ctx. A panic inside
+// fn is recovered and logged at Error level with the context id and stack
+// instead of crashing the process.
+func (l *Log) Go(ctx context.Context, fn func(ctx context.Context)) {
+	detached := Detach(ctx)
+
+	go func() {
+		defer l.recoverPanic(detached)
+		fn(detached)
+	}()
+}
+
+// recoverPanic logs a recovered panic, it must be called deferred
+func (l *Log) recoverPanic(ctx context.Context) {
+	if recovered := recover(); recovered != nil {
+		l.logPanic(ctx, recovered, "recovered panic in goroutine")
+	}
+}
+
+// logPanic logs the recovered value with the stack of the panicking goroutine
+func (l *Log) logPanic(ctx context.Context, recovered interface{}, message string) {
+	if !l.enabled(ctx, log.ErrorLevel) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, log.ErrorLevel)
+	lp.fields[PanicKey] = fmt.Sprint(recovered)
+	lp.fields[StackKey] = string(debug.Stack())
+	l.emit(log.ErrorLevel, lp, message)
+}
+
+// Pool is a worker pool accepting tasks, e.g. an ants.Pool or a pool of
+// your own built on a channel.
+type Pool interface {
+	Submit(task func()) error
+}
+
+// SubmitWithContext hands task to pool with a detached copy of ctx, so the
+// lines a worker writes carry the context id of the originating request
+// while the task outlives its cancellation. It returns the error of Submit.
+func SubmitWithContext(ctx context.Context, pool Pool, task func(ctx context.Context)) error {
+	detached := Detach(ctx)
+
+	return pool.Submit(func() {
+		task(detached)
+	})
+}
@@ -0,0 +1,73 @@
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c2fo/testify/assert"
+)
+
+func TestGoRecoversPanicWithContextId(t *testing.T) {
+	goLogger, hook := NewLoggerWithTestHook(sampleString)
+	goLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	ctx, cancel := context.WithCancel(sampleContext)
+	cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	goLogger.Go(ctx, func(ctx context.Context) {
+		defer wg.Done()
+		assert.Nil(t, ctx.Err())
+		panic("boom")
+	})
+	wg.Wait()
+
+	// the panic is logged after the deferred Done
+	deadline := time.Now().Add(time.Second)
+	for hook.LastEntry() == nil && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	entry := hook.LastEntry()
+	assert.NotNil(t, entry)
+	assert.Equal(t, "boom", entry.Data[PanicKey])
+	assert.Equal(t, "11", entry.Data[ContextIdKey])
+}
+
+// channelPool runs the submitted tasks on one long lived worker
+type channelPool chan func()
+
+func (p channelPool) Submit(task func()) error {
+	p <- task
+	return nil
+}
+
+func TestSubmitWithContext(t *testing.T) {
+	poolLogger, hook := NewLoggerWithTestHook(sampleString)
+	poolLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	pool := make(channelPool)
+	go func() {
+		for task := range pool {
+			task()
+		}
+	}()
+	defer close(pool)
+
+	ctx, cancel := context.WithCancel(requestWithContext.Context())
+	done := make(chan struct{})
+	err := SubmitWithContext(ctx, pool, func(ctx context.Context) {
+		defer close(done)
+		cancel()
+		assert.Nil(t, ctx.Err())
+		poolLogger.Info(ctx, "from worker")
+	})
+	assert.Nil(t, err)
+	<-done
+
+	assert.Equal(t, "12", hook.LastEntry().Data[ContextIdKey])
+}
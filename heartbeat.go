@@ -0,0 +1,66 @@
+package log
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// processStart anchors the uptime reported by heartbeats
+var processStart = time.Now()
+
+// StartHeartbeat logs message at Info every interval, with the process
+// uptime, the number of goroutines and the allocated heap, until ctx is
+// canceled or the returned stop function is called. Stop may be called
+// more than once and returns once the heartbeat goroutine is gone. An
+// interval that is not positive starts nothing, it is reported by a Warn
+// line and stop does nothing.
+func (l *Log) StartHeartbeat(ctx context.Context, interval time.Duration, message string) (stop func()) {
+	if interval <= 0 {
+		l.Warnf(ctx, "heartbeat not started, interval is not positive: %v", interval)
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				l.heartbeat(ctx, message)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(cancel)
+		<-done
+	}
+}
+
+func (l *Log) heartbeat(ctx context.Context, message string) {
+	if !l.enabled(ctx, log.InfoLevel) {
+		return
+	}
+
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	lp := l.newLogParams(ctx, log.InfoLevel)
+	lp.fields[UptimeKey] = time.Since(processStart).Milliseconds()
+	lp.fields[GoroutinesKey] = runtime.NumGoroutine()
+	lp.fields[HeapAllocKey] = stats.HeapAlloc
+	l.emit(log.InfoLevel, lp, message)
+}
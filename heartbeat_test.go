@@ -0,0 +1,42 @@
+package log
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestHeartbeatStops(t *testing.T) {
+	beatLogger, hook := NewLoggerWithTestHook(sampleString)
+	beatLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	stop := beatLogger.StartHeartbeat(sampleContext, time.Millisecond, "alive")
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	stop()
+
+	count := len(hook.AllEntries())
+	assert.True(t, count > 0)
+	assert.Equal(t, "alive", hook.LastEntry().Message)
+	assert.Equal(t, "11", hook.LastEntry().Data[ContextIdKey])
+	_, ok := hook.LastEntry().Data[UptimeKey].(int64)
+	assert.True(t, ok)
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, count, len(hook.AllEntries()))
+}
+
+func TestHeartbeatRejectsInterval(t *testing.T) {
+	beatLogger, hook := NewLoggerWithTestHook(sampleString)
+	beatLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		stop := beatLogger.StartHeartbeat(sampleContext, interval, "alive")
+		stop()
+		assert.Equal(t, log.WarnLevel, hook.LastEntry().Level)
+	}
+	assert.Equal(t, 2, len(hook.AllEntries()))
+}
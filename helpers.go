@@ -0,0 +1,235 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maximumTransformBytes caps each side logged by LogTransform
+const maximumTransformBytes = 16 << 10
+
+// LogTransform logs a payload before and after a transformation at Debug,
+// under the "before" and "after" fields. Both are redacted, encoded as
+// nested JSON when possible and cut to 16KB of JSON each.
+func (l *Log) LogTransform(ctx context.Context, before, after interface{}) {
+	if !l.enabled(ctx, log.DebugLevel) {
+		return
+	}
+
+	redact := l.redaction.Load()
+
+	lp := l.newLogParams(ctx, log.DebugLevel)
+	lp.fields[BeforeKey] = transformValue(before, redact)
+	lp.fields[AfterKey] = transformValue(after, redact)
+	l.emit(log.DebugLevel, lp, "transform")
+}
+
+// LogValidationErrors logs the validation failures of a request at Warn, as
+// a "validation_errors" object mapping each invalid field to its error,
+// with their number in "validation_error_count".
+func (l *Log) LogValidationErrors(ctx context.Context, errs map[string]string) {
+	if !l.enabled(ctx, log.WarnLevel) {
+		return
+	}
+
+	validationErrors := make(map[string]string, len(errs))
+	for field, message := range errs {
+		validationErrors[field] = message
+	}
+
+	lp := l.newLogParams(ctx, log.WarnLevel)
+	lp.fields[ValidationErrorsKey] = validationErrors
+	lp.fields[ValidationErrorCountKey] = len(validationErrors)
+	l.emit(log.WarnLevel, lp, "validation failed")
+}
+
+// LogRetry logs a failed attempt of an operation retried up to maxAttempts
+// times with the "attempt", "max_attempts" and "retry_delay_ms" fields and
+// err, its StructuredError fields included. Attempts count from 1. It logs
+// at Warn, or at Error without retry_delay_ms once the last attempt failed.
+func (l *Log) LogRetry(ctx context.Context, attempt int, maxAttempts int, delay time.Duration, err error) {
+	level, message := log.WarnLevel, "retrying"
+	if attempt >= maxAttempts {
+		level, message = log.ErrorLevel, "retries exhausted"
+	}
+	if !l.enabled(ctx, level) {
+		return
+	}
+
+	args := []interface{}{err}
+	lp := l.newLogParams(ctx, level).injectErrorFields(args).injectErrorContext(level, args)
+	lp.fields[AttemptKey] = attempt
+	lp.fields[MaxAttemptsKey] = maxAttempts
+	if level == log.WarnLevel {
+		lp.fields[RetryDelayKey] = delay.Milliseconds()
+	}
+	if err != nil {
+		lp.fields[log.ErrorKey] = err
+	}
+	l.emit(level, lp, message)
+}
+
+// LogResult logs the outcome of operation in one line: "operation", a
+// "success" boolean, err with its StructuredError fields when it failed,
+// "duration_ms" when ctx comes from StartTimer, and fields, which never
+// replace the fields above. It logs at Info on success and at Error on
+// failure.
+func (l *Log) LogResult(ctx context.Context, operation string, err error, fields map[string]interface{}) {
+	level, message := log.InfoLevel, operation+" succeeded"
+	if err != nil {
+		level, message = log.ErrorLevel, operation+" failed"
+	}
+	if !l.enabled(ctx, level) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, level)
+	if err != nil {
+		args := []interface{}{err}
+		lp.injectErrorFields(args).injectErrorContext(level, args)
+		lp.fields[log.ErrorKey] = err
+	}
+	lp.fields[OperationKey] = operation
+	lp.fields[SuccessKey] = err == nil
+	if elapsed, ok := timerElapsed(ctx); ok {
+		lp.fields[DurationKey] = elapsed.Milliseconds()
+	}
+	l.addDataMap(lp, fields)
+	l.emit(level, lp, message)
+}
+
+// safe typing https://golang.org/pkg/context/#WithValue
+type stepsKeyType string
+
+const stepsKey stepsKeyType = "steps"
+
+// withSteps gives ctx a step count of its own, installed with every new
+// context id
+func withSteps(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stepsKey, &atomic.Int64{})
+}
+
+// Step logs a step of a workflow at Info with the "step" and "prev_step"
+// fields, prev_step left out when empty, and "step_num", counting the steps
+// of the context id from 1, so the workflow can be replayed in order from
+// its lines. The later lines of the context carry the last step_num too.
+// Steps may be logged from several goroutines; without a context id, e.g.
+// on context.Background(), step_num is left out.
+func (l *Log) Step(ctx context.Context, stepName string, prevStep string) {
+	stepNum := 0
+	if steps, ok := ctx.Value(stepsKey).(*atomic.Int64); ok {
+		stepNum = int(steps.Add(1))
+	}
+	if !l.enabled(ctx, log.InfoLevel) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, log.InfoLevel)
+	lp.fields[StepKey] = stepName
+	if prevStep != "" {
+		lp.fields[PrevStepKey] = prevStep
+	}
+	if stepNum > 0 {
+		lp.fields[StepNumKey] = stepNum
+	}
+	l.emit(log.InfoLevel, lp, "step "+stepName)
+}
+
+func (lp *LogParams) injectStepNum(ctx context.Context) *LogParams {
+	if steps, ok := ctx.Value(stepsKey).(*atomic.Int64); ok {
+		if stepNum := steps.Load(); stepNum > 0 {
+			lp.fields[StepNumKey] = int(stepNum)
+		}
+	}
+	return lp
+}
+
+// tokenClaims are the JWT claims LogTokenClaims may log
+var tokenClaims = []string{"sub", "iss", "exp", "aud"}
+
+// LogTokenClaims decodes, without verifying it, the payload of the JWT
+// token and logs its sub, iss, exp and aud claims at Debug under
+// "token_claims", the claims of Config.SensitiveClaims redacted. Neither
+// the token nor its signature is ever logged. It does nothing unless
+// Config.EnableTokenClaims is set, for debugging authentication only.
+func (l *Log) LogTokenClaims(ctx context.Context, token string) {
+	if !l.config.EnableTokenClaims || !l.enabled(ctx, log.DebugLevel) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, log.DebugLevel)
+	claims, err := decodeTokenClaims(token)
+	if err != nil {
+		lp.fields[log.ErrorKey] = err
+		l.emit(log.DebugLevel, lp, "undecodable token")
+		return
+	}
+
+	logged := make(map[string]interface{}, len(tokenClaims))
+	for _, name := range tokenClaims {
+		value, ok := claims[name]
+		if !ok {
+			continue
+		}
+		for _, sensitive := range l.config.SensitiveClaims {
+			if name == sensitive {
+				value = RedactedValue
+			}
+		}
+		logged[name] = value
+	}
+	lp.fields[TokenClaimsKey] = logged
+	l.emit(log.DebugLevel, lp, "token claims")
+}
+
+// decodeTokenClaims decodes the payload segment of a JWT
+func decodeTokenClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(token, "Bearer "), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token has %d segments, want 3", len(parts))
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("token payload is not base64url: %v", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var claims map[string]interface{}
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("token payload is not a JSON object: %v", err)
+	}
+	return claims, nil
+}
+
+// transformValue returns value as decoded JSON, or as a truncated string
+// when its encoding is too large or fails
+func transformValue(value interface{}, redact bool) interface{} {
+	if redact {
+		value = redactStructTags(value, nil)
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return truncateMessage(fmt.Sprintf("%+v", value), maximumTransformBytes)
+	}
+	if len(encoded) > maximumTransformBytes {
+		return truncateMessage(string(encoded), maximumTransformBytes)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return string(encoded)
+	}
+	return decoded
+}
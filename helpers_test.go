@@ -0,0 +1,176 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLogTransform(t *testing.T) {
+	transformLogger, hook := NewLoggerWithTestHook(sampleString)
+	transformLogger.GetEntry().Logger.Out = ioutil.Discard
+	transformLogger.SetLevel(log.DebugLevel)
+
+	transformLogger.LogTransform(sampleContext,
+		redactUser{Name: "fakhri", Password: "secret"},
+		map[string]interface{}{"blob": strings.Repeat("x", maximumTransformBytes)})
+
+	entry := hook.LastEntry()
+	assert.Equal(t, log.DebugLevel, entry.Level)
+	assert.Equal(t, RedactedValue, entry.Data[BeforeKey].(map[string]interface{})["password"])
+	assert.True(t, strings.HasSuffix(entry.Data[AfterKey].(string), truncatedSuffix))
+}
+
+func TestLogValidationErrors(t *testing.T) {
+	validationLogger, hook := NewLoggerWithTestHook(sampleString)
+	validationLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	validationLogger.LogValidationErrors(sampleContext, map[string]string{
+		"email": "must be a valid email",
+		"age":   "must be positive",
+	})
+
+	entry := hook.LastEntry()
+	assert.Equal(t, log.WarnLevel, entry.Level)
+	assert.Equal(t, "validation failed", entry.Message)
+	assert.Equal(t, "must be positive", entry.Data[ValidationErrorsKey].(map[string]string)["age"])
+	assert.Equal(t, 2, entry.Data[ValidationErrorCountKey])
+}
+
+func TestLogTokenClaims(t *testing.T) {
+	// {"alg":"HS256"} . {"sub":"42","iss":"auth","exp":1700000000,"email":"a@b.c"} . signature
+	token := "eyJhbGciOiJIUzI1NiJ9." +
+		"eyJzdWIiOiI0MiIsImlzcyI6ImF1dGgiLCJleHAiOjE3MDAwMDAwMDAsImVtYWlsIjoiYUBiLmMifQ." +
+		"c2lnbmF0dXJl"
+
+	disabledLogger, disabledHook := NewLoggerWithTestHook(sampleString)
+	disabledLogger.GetEntry().Logger.Out = ioutil.Discard
+	disabledLogger.SetLevel(log.DebugLevel)
+	disabledLogger.LogTokenClaims(sampleContext, token)
+	assert.Nil(t, disabledHook.LastEntry())
+
+	claimsLogger := NewLoggerWithConfig(Config{Service: sampleString, EnableTokenClaims: true, SensitiveClaims: []string{"sub"}})
+	claimsLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(claimsLogger.GetEntry().Logger)
+
+	// Debug only
+	claimsLogger.LogTokenClaims(sampleContext, token)
+	assert.Nil(t, hook.LastEntry())
+
+	claimsLogger.SetLevel(log.DebugLevel)
+	claimsLogger.LogTokenClaims(sampleContext, "Bearer "+token)
+	entry := hook.LastEntry()
+	assert.Equal(t, "token claims", entry.Message)
+	assert.Equal(t, map[string]interface{}{
+		"sub": RedactedValue,
+		"iss": "auth",
+		"exp": json.Number("1700000000"),
+	}, entry.Data[TokenClaimsKey])
+
+	claimsLogger.LogTokenClaims(sampleContext, "not-a-token")
+	assert.Equal(t, "undecodable token", hook.LastEntry().Message)
+}
+
+func TestLogRetry(t *testing.T) {
+	retryLogger, hook := NewLoggerWithTestHook(sampleString)
+	retryLogger.GetEntry().Logger.Out = ioutil.Discard
+	err := WrapError(errors.New("connection refused"), map[string]interface{}{"host": "db"})
+
+	retryLogger.LogRetry(sampleContext, 1, 3, 200*time.Millisecond, err)
+	entry := hook.LastEntry()
+	assert.Equal(t, log.WarnLevel, entry.Level)
+	assert.Equal(t, 1, entry.Data[AttemptKey])
+	assert.Equal(t, 3, entry.Data[MaxAttemptsKey])
+	assert.Equal(t, int64(200), entry.Data[RetryDelayKey])
+	assert.Equal(t, err, entry.Data[log.ErrorKey])
+	assert.Equal(t, "db", entry.Data["host"])
+
+	retryLogger.LogRetry(sampleContext, 3, 3, 0, err)
+	entry = hook.LastEntry()
+	assert.Equal(t, log.ErrorLevel, entry.Level)
+	assert.Equal(t, "retries exhausted", entry.Message)
+	_, ok := entry.Data[RetryDelayKey]
+	assert.False(t, ok)
+}
+
+func TestLogResult(t *testing.T) {
+	resultLogger, hook := NewLoggerWithTestHook(sampleString)
+	resultLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	resultLogger.LogResult(StartTimer(sampleContext), "charge", nil, map[string]interface{}{"amount": 10, SuccessKey: false})
+	entry := hook.LastEntry()
+	assert.Equal(t, log.InfoLevel, entry.Level)
+	assert.Equal(t, "charge succeeded", entry.Message)
+	assert.Equal(t, "charge", entry.Data[OperationKey])
+	assert.Equal(t, true, entry.Data[SuccessKey])
+	assert.Equal(t, 10, entry.Data["amount"])
+	_, ok := entry.Data[DurationKey].(int64)
+	assert.True(t, ok)
+
+	err := WrapError(errors.New("card declined"), map[string]interface{}{"gateway": "stripe"})
+	resultLogger.LogResult(sampleContext, "charge", err, nil)
+	entry = hook.LastEntry()
+	assert.Equal(t, log.ErrorLevel, entry.Level)
+	assert.Equal(t, false, entry.Data[SuccessKey])
+	assert.Equal(t, err, entry.Data[log.ErrorKey])
+	assert.Equal(t, "stripe", entry.Data["gateway"])
+	_, ok = entry.Data[DurationKey]
+	assert.False(t, ok)
+}
+
+func TestStep(t *testing.T) {
+	stepLogger, hook := NewLoggerWithTestHook(sampleString)
+	stepLogger.GetEntry().Logger.Out = ioutil.Discard
+	ctx := stepLogger.BuildContextDataAndSetValue("workflow-1")
+
+	stepLogger.Step(ctx, "reserve", "")
+	stepLogger.Step(ctx, "charge", "reserve")
+	stepLogger.Info(ctx, "charged")
+
+	entries := hook.AllEntries()
+	assert.Equal(t, "reserve", entries[0].Data[StepKey])
+	assert.Equal(t, 1, entries[0].Data[StepNumKey])
+	_, ok := entries[0].Data[PrevStepKey]
+	assert.False(t, ok)
+	assert.Equal(t, "reserve", entries[1].Data[PrevStepKey])
+	assert.Equal(t, 2, entries[1].Data[StepNumKey])
+	assert.Equal(t, "workflow-1", entries[1].Data[ContextIdKey])
+	assert.Equal(t, 2, entries[2].Data[StepNumKey])
+}
+
+// TestStepConcurrently is meant to run with -race as well
+func TestStepConcurrently(t *testing.T) {
+	stepLogger, hook := NewLoggerWithTestHook(sampleString)
+	stepLogger.GetEntry().Logger.Out = ioutil.Discard
+	ctx := stepLogger.BuildContextDataAndSetValue("workflow-2")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stepLogger.Step(ctx, "fan-out", "")
+		}()
+	}
+	wg.Wait()
+
+	seen := map[interface{}]bool{}
+	for _, entry := range hook.AllEntries() {
+		seen[entry.Data[StepNumKey]] = true
+	}
+	assert.Equal(t, 50, len(seen))
+	assert.True(t, seen[1])
+	assert.True(t, seen[50])
+
+	// a child context counts its own steps
+	stepLogger.Step(NewChildContext(ctx), "child", "")
+	assert.Equal(t, 1, hook.LastEntry().Data[StepNumKey])
+}
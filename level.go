@@ -0,0 +1,220 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Level is the severity of a line. It is an alias of logrus.Level, so the
+// levels of either package can be passed around, and the constants below
+// spare the callers of Entry and Recorder an import of logrus.
+type Level = log.Level
+
+const (
+	PanicLevel = log.PanicLevel
+	FatalLevel = log.FatalLevel
+	ErrorLevel = log.ErrorLevel
+	WarnLevel  = log.WarnLevel
+	InfoLevel  = log.InfoLevel
+	DebugLevel = log.DebugLevel
+	TraceLevel = log.TraceLevel
+)
+
+// levels holds the global level and the per category levels of a logger and
+// of every logger derived from it
+type levels struct {
+	mu         sync.RWMutex
+	global     log.Level
+	categories map[string]log.Level
+
+	// pushed holds the global levels saved by PushLevel
+	pushed []log.Level
+
+	// logger is kept at the most verbose level in use so logrus lets every
+	// line through that one of the categories asks for
+	logger *log.Logger
+
+	// synced is the level last set on logger, a different level was set
+	// on logger directly
+	synced log.Level
+
+	// requestLevels keeps logger at TraceLevel, the level of a request set
+	// through HTTPConfig.LevelHeader is only known at log time
+	requestLevels bool
+}
+
+func newLevels(logger *log.Logger) *levels {
+	return &levels{
+		global:     logger.GetLevel(),
+		categories: make(map[string]log.Level),
+		logger:     logger,
+		synced:     logger.GetLevel(),
+	}
+}
+
+// adoptLoggerLevel makes a level set on the logrus logger directly, e.g.
+// with GetEntry().Logger.SetLevel, the global level
+func (s *levels) adoptLoggerLevel() {
+	current := s.logger.GetLevel()
+	s.mu.RLock()
+	synced := s.synced
+	s.mu.RUnlock()
+	if current == synced {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if current = s.logger.GetLevel(); current != s.synced {
+		s.global = current
+		s.syncLogger()
+	}
+}
+
+func (s *levels) setGlobal(level log.Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.global = level
+	s.syncLogger()
+}
+
+func (s *levels) push(level log.Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pushed = append(s.pushed, s.global)
+	s.global = level
+	s.syncLogger()
+}
+
+func (s *levels) pop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pushed) == 0 {
+		return
+	}
+
+	last := len(s.pushed) - 1
+	s.global = s.pushed[last]
+	s.pushed = s.pushed[:last]
+	s.syncLogger()
+}
+
+func (s *levels) setCategory(category string, level log.Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.categories[category] = level
+	s.syncLogger()
+}
+
+// get returns the level of category, or the global level when it has none
+func (s *levels) get(category string) log.Level {
+	s.adoptLoggerLevel()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if level, ok := s.categories[category]; ok && category != "" {
+		return level
+	}
+
+	return s.global
+}
+
+func (s *levels) enabled(category string, level log.Level) bool {
+	return s.get(category) >= level
+}
+
+// allowRequestLevels lets the lines of a request below the logger levels
+// through logrus
+func (s *levels) allowRequestLevels() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requestLevels = true
+	s.syncLogger()
+}
+
+// syncLogger must be called with mu held
+func (s *levels) syncLogger() {
+	verbose := s.global
+	for _, level := range s.categories {
+		if level > verbose {
+			verbose = level
+		}
+	}
+	if s.requestLevels {
+		verbose = log.TraceLevel
+	}
+
+	s.logger.SetLevel(verbose)
+	s.synced = verbose
+}
+
+// PushLevel saves the current global level and replaces it by level until
+// the matching PopLevel. Pushes nest, the stack is shared by every logger
+// derived from the same root.
+func (l *Log) PushLevel(level log.Level) {
+	l.levels.push(level)
+}
+
+// PopLevel restores the global level saved by the last PushLevel, it does
+// nothing when no level is pushed.
+func (l *Log) PopLevel() {
+	l.levels.pop()
+}
+
+// SetCategoryLevel sets the level of the loggers created by Named(category),
+// independently of the global level set by SetLevel.
+func (l *Log) SetCategoryLevel(category string, level log.Level) {
+	l.levels.setCategory(category, level)
+}
+
+// Named returns a logger for the category, it adds a "category" field to
+// every line and follows the level set by SetCategoryLevel for the category.
+func (l *Log) Named(category string) Logger {
+	named := *l
+	named.entry = l.entry.WithField(CategoryKey, category)
+	named.category = category
+	return &named
+}
+
+// safe typing https://golang.org/pkg/context/#WithValue
+type requestLevelKeyType string
+
+const requestLevelKey requestLevelKeyType = "request_level"
+
+// withRequestLevel makes the lines of ctx written down to level
+func withRequestLevel(ctx context.Context, level log.Level) context.Context {
+	return context.WithValue(ctx, requestLevelKey, level)
+}
+
+// requestLevelEnabled reports whether the level of the request of ctx lets a
+// line at level through
+func requestLevelEnabled(ctx context.Context, level log.Level) bool {
+	requestLevel, ok := ctx.Value(requestLevelKey).(log.Level)
+	return ok && level <= requestLevel
+}
+
+// readLevelHeader returns the level asked for by the level header of r, when
+// the header is configured, present, valid and authorized
+func readLevelHeader(r *http.Request, cfg *HTTPConfig) (log.Level, bool) {
+	value := r.Header.Get(cfg.LevelHeader)
+	if value == "" || cfg.AuthorizeLevelHeader == nil {
+		return 0, false
+	}
+
+	level, err := log.ParseLevel(value)
+	if err != nil || !cfg.AuthorizeLevelHeader(r) {
+		return 0, false
+	}
+
+	return level, true
+}
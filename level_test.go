@@ -0,0 +1,66 @@
+package log
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestCategoryLevel(t *testing.T) {
+	rootLogger, hook := NewLoggerWithTestHook(sampleString)
+	rootLogger.GetEntry().Logger.Out = ioutil.Discard
+	rootLogger.SetCategoryLevel("http", log.DebugLevel)
+	rootLogger.SetCategoryLevel("db", log.WarnLevel)
+
+	rootLogger.Debug(sampleContext, "root debug")
+	rootLogger.Named("db").Info(sampleContext, "db info")
+	assert.Equal(t, 0, len(hook.AllEntries()))
+
+	rootLogger.Named("http").Debug(sampleContext, "http debug")
+	entry := hook.LastEntry()
+	assert.NotNil(t, entry)
+	assert.Equal(t, "http", entry.Data[CategoryKey])
+
+	rootLogger.Named("db").Warn(sampleContext, "db warn")
+	assert.Equal(t, 2, len(hook.AllEntries()))
+}
+
+func TestPushPopLevel(t *testing.T) {
+	stackLogger, hook := NewLoggerWithTestHook(sampleString)
+	stackLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	stackLogger.PushLevel(log.DebugLevel)
+	stackLogger.PushLevel(log.ErrorLevel)
+	stackLogger.Info(sampleContext, "dropped")
+	assert.Equal(t, 0, len(hook.AllEntries()))
+
+	stackLogger.PopLevel()
+	stackLogger.Debug(sampleContext, "kept")
+	assert.Equal(t, 1, len(hook.AllEntries()))
+
+	stackLogger.PopLevel()
+	stackLogger.PopLevel()
+	stackLogger.Debug(sampleContext, "dropped")
+	stackLogger.Info(sampleContext, "kept")
+	assert.Equal(t, 2, len(hook.AllEntries()))
+}
+
+func TestLogrusSetLevelStillApplies(t *testing.T) {
+	levelLogger, hook := NewLoggerWithTestHook(sampleString)
+	levelLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	levelLogger.GetEntry().Logger.SetLevel(log.DebugLevel)
+	levelLogger.Debug(sampleContext, "debug")
+	assert.Equal(t, 1, len(hook.AllEntries()))
+
+	levelLogger.GetEntry().Logger.SetLevel(log.WarnLevel)
+	levelLogger.Info(sampleContext, "info")
+	assert.Equal(t, 1, len(hook.AllEntries()))
+
+	// SetLevel still wins once called
+	levelLogger.SetLevel(log.InfoLevel)
+	levelLogger.Info(sampleContext, "info")
+	assert.Equal(t, 2, len(hook.AllEntries()))
+}
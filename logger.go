@@ -1,21 +1,40 @@
 package log
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"regexp"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Logger interface {
 	SetLevel(level log.Level)
+	PushLevel(level log.Level)
+	PopLevel()
+	SetCategoryLevel(category string, level log.Level)
+
+	Named(category string) Logger
+	WithFields(fields map[string]interface{}) Logger
+	WithComponent(name string) Logger
+
+	SetRedactionEnabled(enabled bool)
 
 	BuildContextDataAndSetValue(contextId string) (ctx context.Context)
 	AppendContextDataAndSetValue(r *http.Request, contextId string) *http.Request
@@ -27,19 +46,71 @@ type Logger interface {
 
 	Infof(ctx context.Context, message string, args ...interface{})
 	Errorf(ctx context.Context, message string, args ...interface{})
+	InfofAt(ctx context.Context, frame runtime.Frame, message string, args ...interface{})
+	ErrorfAt(ctx context.Context, frame runtime.Frame, message string, args ...interface{})
 	Warnf(ctx context.Context, message string, args ...interface{})
 	Debugf(ctx context.Context, message string, args ...interface{})
 	Fatalf(ctx context.Context, message string, args ...interface{})
+	Tracef(ctx context.Context, message string, args ...interface{})
+	Panicf(ctx context.Context, message string, args ...interface{})
 	Info(ctx context.Context, args ...interface{})
 	Error(ctx context.Context, args ...interface{})
 	Warn(ctx context.Context, args ...interface{})
 	Debug(ctx context.Context, args ...interface{})
 	Fatal(ctx context.Context, args ...interface{})
+	Trace(ctx context.Context, args ...interface{})
+	Panic(ctx context.Context, args ...interface{})
+
+	Log(ctx context.Context, level log.Level, args ...interface{})
+	Logf(ctx context.Context, level log.Level, message string, args ...interface{})
 
 	InfoMap(ctx context.Context, dataMap map[string]interface{}, args ...interface{})
+	DebugMap(ctx context.Context, dataMap map[string]interface{}, args ...interface{})
+	WarnMap(ctx context.Context, dataMap map[string]interface{}, args ...interface{})
+	ErrorMap(ctx context.Context, dataMap map[string]interface{}, args ...interface{})
+	LogMap(ctx context.Context, level log.Level, dataMap map[string]interface{}, args ...interface{})
+	InfoMapf(ctx context.Context, dataMap map[string]interface{}, format string, args ...interface{})
+
+	InfoSummary(ctx context.Context, summary string, detail map[string]interface{})
+	WarnSummary(ctx context.Context, summary string, detail map[string]interface{})
+	ErrorSummary(ctx context.Context, summary string, detail map[string]interface{})
+	DebugSummary(ctx context.Context, summary string, detail map[string]interface{})
 
 	LogRequest(ctx context.Context, r *http.Request)
+	LogRequestWithOptions(ctx context.Context, r *http.Request, opts RequestLogOptions)
 	LogResponse(ctx context.Context, rw *LoggingResponseWriter)
+	LogTransform(ctx context.Context, before, after interface{})
+	LogValidationErrors(ctx context.Context, errs map[string]string)
+	LogTokenClaims(ctx context.Context, token string)
+	LogRetry(ctx context.Context, attempt int, maxAttempts int, delay time.Duration, err error)
+	LogResult(ctx context.Context, operation string, err error, fields map[string]interface{})
+	Step(ctx context.Context, stepName string, prevStep string)
+	Config() ConfigSnapshot
+
+	WithContext(ctx context.Context) ContextLogger
+	Tracer(ctx context.Context) ContextLogger
+	Slog(ctx context.Context) *slog.Logger
+
+	Go(ctx context.Context, fn func(ctx context.Context))
+	StartHeartbeat(ctx context.Context, interval time.Duration, message string) (stop func())
+	LogGoroutineDump(ctx context.Context)
+	SetWriteErrorHandler(handler func(error))
+	SetFieldTransform(transform FieldTransform)
+	SetServiceInfo(name, version, instance string)
+	SetPIIScrubbing(enabled bool)
+	AddPIIPattern(name string, pattern *regexp.Regexp)
+	RemovePIIPattern(name string)
+	WriteStats() WriteStats
+	Progress(ctx context.Context, operation string, current, total int)
+
+	OnShutdown(fn func())
+	Shutdown(ctx context.Context) error
+	SetBatch(maxEntries int, maxWait time.Duration)
+
+	Middleware(next http.Handler) http.Handler
+	MiddlewareWithOptions(opts RequestLogOptions, next http.Handler) http.Handler
+
+	JSONLineWriter(ctx context.Context) io.WriteCloser
 }
 
 // safe typing https://golang.org/pkg/context/#WithValue
@@ -47,7 +118,8 @@ type contextDataMapKeyType string
 
 // add key here for future request based value
 var (
-	// ContextDataMapKey is a key for data map that contain values
+	// ContextDataMapKey is a key for data map that contain values, a
+	// map[string]interface{}; a map[string]string stored under it is read too
 	ContextDataMapKey contextDataMapKeyType = "value"
 
 	// context key data added to map
@@ -56,14 +128,189 @@ var (
 	RequestKey      = "request"
 	ResponseKey     = "response"
 	ResponseCodeKey = "response_code"
+	HandlerKey      = "handler"
+	BeforeKey       = "before"
+	AfterKey        = "after"
+
+	// body keys added with HTTPConfig.Base64Bodies
+	RequestB64Key   = "request_b64"
+	ResponseB64Key  = "response_b64"
+	BodyEncodingKey = "body_encoding"
+
+	// body keys added when a body is cut by HTTPConfig.MaxBodyBytes, the
+	// request length only when the client sent a Content-Length
+	RequestTruncatedKey  = "request_truncated"
+	RequestLengthKey     = "request_length"
+	ResponseTruncatedKey = "response_truncated"
+	ResponseLengthKey    = "response_length"
+
+	// request keys added with RequestLogOptions
+	QueryKey          = "url_query"
+	RequestHeadersKey = "request_headers"
+	RemoteAddrKey     = "remote_addr"
+
+	// response key added with HTTPConfig.ResponseHeaders
+	ResponseHeadersKey = "response_headers"
+
+	// keys added by LogValidationErrors
+	ValidationErrorsKey     = "validation_errors"
+	ValidationErrorCountKey = "validation_error_count"
+
+	// keys added by LogRetry
+	AttemptKey     = "attempt"
+	MaxAttemptsKey = "max_attempts"
+	RetryDelayKey  = "retry_delay_ms"
+
+	// keys added by Step
+	StepKey     = "step"
+	PrevStepKey = "prev_step"
+	StepNumKey  = "step_num"
+
+	// keys added by LogResult, OperationKey by Progress as well
+	OperationKey = "operation"
+	SuccessKey   = "success"
+
+	// keys added by Progress
+	CurrentKey     = "current"
+	TotalKey       = "total"
+	ProgressPctKey = "progress_pct"
+
+	// key added by LogTokenClaims
+	TokenClaimsKey = "token_claims"
+
+	// key added with Config.LogRedactedKeys
+	RedactedKeysKey = "_redacted_keys"
+
+	// HTTP/2 key added by Middleware, see WithStreamID
+	StreamIdKey = "stream_id"
+
+	// key added with HTTPConfig.CorrelationHash
+	CorrelationHashKey = "correlation_hash"
+
+	// access log keys added with HTTPConfig.LogUserAgent
+	UserAgentKey = "user_agent"
+	RefererKey   = "referer"
+
+	// key added by NewChildContext
+	ParentContextIdKey = "parent_context_id"
+
+	// caller keys added on error and above, see Config.CallerLevel
+	FuncKey = "func"
+	FileKey = "file"
+	LineKey = "line"
+
+	// trace keys added by Tracer and WithTrace
+	TraceIdKey = "trace_id"
+	SpanIdKey  = "span_id"
+
+	// access log key added by MarkCacheHit
+	CacheHitKey = "cache_hit"
+
+	// access log keys added with HTTPConfig.AccessLogStyle
+	MethodKey   = "http_method"
+	DurationKey = "duration_ms"
+
+	// access log key added with HTTPConfig.LogClockJumps
+	ClockJumpKey = "clock_jump_ms"
+
+	// trace sampling key added with HTTPConfig.LogTraceSampled
+	SampledKey = "sampled"
+
+	// context error keys added with Config.LogContextError
+	ContextErrorKey = "ctx_err"
+	ContextCauseKey = "ctx_cause"
+
+	// deadline key added with Config.LogDeadline
+	DeadlineRemainingKey = "deadline_remaining_ms"
+
+	// sequence key added with Config.Sequence
+	SeqKey = "seq"
+
+	// composite error key added with Config.ErrorContextTemplate
+	ErrorContextKey = "err_ctx"
+
+	// deployment environment key added with Config.Env
+	EnvKey = "env"
+
+	// identity keys, service from Config.Service, all set by SetServiceInfo
+	ServiceKey  = "service"
+	VersionKey  = "version"
+	InstanceKey = "instance"
+
+	// keys added by StartHeartbeat
+	UptimeKey     = "uptime_ms"
+	GoroutinesKey = "goroutines"
+	HeapAllocKey  = "heap_alloc_bytes"
+
+	// category key added by Named
+	CategoryKey = "category"
+
+	// component key added by WithComponent
+	ComponentKey = "component"
+
+	// recovered panic keys, StackKey is used by LogGoroutineDump as well
+	PanicKey = "panic"
+	StackKey = "stack"
+
+	// keys added by LogGoroutineDump
+	PartKey      = "part"
+	PartsKey     = "parts"
+	TruncatedKey = "truncated"
 )
 
 type Log struct {
-	entry *log.Entry
+	entry    *log.Entry
+	config   *Config
+	levels   *levels
+	shutdown *shutdown
+	progress *progress
+
+	// writeErrors handles the errors of the writers
+	writeErrors *writeErrors
+
+	// seq numbers the lines with Config.Sequence
+	seq *atomic.Uint64
+
+	// writeLimit bounds the writes in progress
+	writeLimit *writeLimit
+
+	// fieldTransform holds the transform set by SetFieldTransform
+	fieldTransform *atomic.Pointer[FieldTransform]
+
+	// redaction tells whether field values are redacted before writing
+	redaction *atomic.Bool
+
+	// missingContextIds holds the callers reported by Config.StrictContext
+	missingContextIds *sync.Map
+
+	// pii holds the scrubbing of SetPIIScrubbing
+	pii *piiScrubbing
+
+	// serviceInfo holds the identity set by SetServiceInfo
+	serviceInfo *atomic.Pointer[serviceInfo]
+
+	// category selects the level set by SetCategoryLevel, empty for the root logger
+	category string
+
+	// callerLevel is the least severe level whose lines get the caller fields
+	callerLevel log.Level
 }
 
 type LogParams struct {
 	fields log.Fields
+	config *Config
+
+	// rules mask the HTTP bodies, headers and query, nil when redaction is
+	// off or there are no rules
+	rules map[string]RedactionRule
+
+	// keys collects the redacted names for Config.LogRedactedKeys
+	keys redactedKeys
+
+	// mirror receives the line as written, once redacted, scrubbed,
+	// transformed and truncated, e.g. to add it to a span; lines dropped
+	// by Config.BackpressurePolicy are not mirrored
+	mirror func(level log.Level, message string, fields log.Fields)
 }
 
 // context key data added to map
@@ -74,6 +321,26 @@ type contextData struct {
 const (
 	maximumCallerDepth int = 25
 	knownLogFrames     int = 3
+
+	// EnvVariable names the environment variable holding the deployment
+	// environment, e.g. production
+	EnvVariable = "APP_ENV"
+
+	// base64Encoding marks a body logged as base64
+	base64Encoding = "base64"
+
+	// maximumUserAgentLength caps the user_agent field
+	maximumUserAgentLength = 512
+
+	// maximumRequestIdLength caps the ids read by HTTPConfig.RequestIdHeader
+	maximumRequestIdLength = 128
+
+	// maximumTrailerBody caps the request body buffered to read the
+	// HTTPConfig.ContextIdTrailer when MaxBodyBytes does not
+	maximumTrailerBody = 1 << 20
+
+	// truncatedSuffix marks a message cut by Config.MaxMessageLength
+	truncatedSuffix = "…[truncated]"
 )
 
 var (
@@ -86,73 +353,198 @@ var (
 	thisPackageName string
 )
 
-func NewLogger(service string) Logger {
-	logger := log.New()
+// NewLogger builds a logger for service, writing JSON lines on stderr from
+// InfoLevel unless opts say otherwise:
+//
+//	logger := log.NewLogger("orders", log.WithLevel(logrus.DebugLevel), log.WithOutput(os.Stdout))
+func NewLogger(service string, opts ...Option) Logger {
+	o := &options{logger: log.New(), config: Config{Service: service}, fields: log.Fields{}}
+	for _, opt := range opts {
+		opt(o)
+	}
 
-	logger.SetFormatter(&log.JSONFormatter{
-		TimestampFormat: time.RFC3339Nano,
-	})
-	entry := log.NewEntry(logger)
-	entry = entry.WithField("service", service)
-	return &Log{entry}
+	l := newLog(o.logger, o.config)
+	if len(o.fields) > 0 {
+		l.entry = l.entry.WithFields(o.fields)
+	}
+	return l
 }
 
+// NewLoggerWithLevel builds a logger for service writing level and above.
+//
+// Deprecated: use NewLogger(service, WithLevel(level)).
 func NewLoggerWithLevel(service string, level log.Level) Logger {
-	logger := log.New()
+	return NewLogger(service, WithLevel(level))
+}
+
+func NewLoggerWithConfig(cfg Config) Logger {
+	return newLog(log.New(), cfg)
+}
+
+// New builds a logger from cfg like NewLoggerWithConfig, but first checks
+// cfg and returns an error naming the first invalid setting.
+func New(cfg Config) (Logger, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return newLog(log.New(), cfg), nil
+}
+
+// MustNew is like New but panics on an invalid cfg, for package level
+// loggers:
+//
+//	var logger = log.MustNew(log.Config{Service: "orders"})
+func MustNew(cfg Config) Logger {
+	logger, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return logger
+}
 
-	logger.SetFormatter(&log.JSONFormatter{
+func newLog(logger *log.Logger, cfg Config) *Log {
+	var formatter log.Formatter = &log.JSONFormatter{
 		TimestampFormat: time.RFC3339Nano,
-	})
-	logger.SetLevel(level)
+	}
+	if cfg.Formatter != nil {
+		formatter = cfg.Formatter
+	} else if cfg.FieldOrder != nil {
+		formatter = &JSONFormatter{FieldOrder: cfg.FieldOrder}
+	}
+	if len(cfg.LevelFormatters) > 0 {
+		formatter = &LevelFormatter{Levels: cfg.LevelFormatters, Default: formatter}
+	}
+	writeErrors := &writeErrors{policy: cfg.WriteErrorPolicy}
+	switch {
+	case cfg.DryRun:
+		logger.SetOutput(ioutil.Discard)
+	case len(cfg.Outputs) > 0:
+		addOutputs(logger, &cfg, writeErrors)
+		formatter = nopFormatter{}
+		logger.SetOutput(ioutil.Discard)
+	default:
+		out := logger.Out
+		if cfg.LengthPrefixed {
+			out = &framedWriter{Writer: out}
+		}
+		logger.SetOutput(&errorWriter{Writer: out, errors: writeErrors})
+		if cfg.AsyncBuffer > 0 {
+			logger.SetOutput(newAsyncWriter(logger.Out, cfg.AsyncBuffer))
+		}
+	}
+	logger.SetFormatter(&fieldFormatter{Formatter: formatter, config: &cfg, notify: true})
+
 	entry := log.NewEntry(logger)
-	entry = entry.WithField("service", service)
-	return &Log{entry}
+	entry = entry.WithField(ServiceKey, cfg.Service)
+	if cfg.Env == "" {
+		cfg.Env = os.Getenv(EnvVariable)
+	}
+	if cfg.Env != "" {
+		entry = entry.WithField(EnvKey, cfg.Env)
+	}
+
+	l := &Log{
+		entry:     entry,
+		config:    &cfg,
+		levels:    newLevels(logger),
+		shutdown:  &shutdown{},
+		progress:  &progress{operations: make(map[string]progressState)},
+		redaction: &atomic.Bool{},
+
+		writeErrors:    writeErrors,
+		writeLimit:     newWriteLimit(&cfg),
+		seq:            &atomic.Uint64{},
+		fieldTransform: &atomic.Pointer[FieldTransform]{},
+		serviceInfo:    &atomic.Pointer[serviceInfo]{},
+		pii:            newPIIScrubbing(),
+		callerLevel:    cfg.callerLevel(),
+
+		missingContextIds: &sync.Map{},
+	}
+	l.redaction.Store(redactionEnabledFor(&cfg))
+	if cfg.HTTP.LevelHeader != "" {
+		l.levels.allowRequestLevels()
+	}
+
+	if cfg.LogInitialization {
+		l.logInitialization()
+	}
+
+	return l
 }
 
 func (l *Log) SetLevel(level log.Level) {
-	l.entry.Logger.SetLevel(level)
+	l.levels.setGlobal(level)
 }
 
 func (l *Log) getContextData(ctx context.Context) *contextData {
-	dataMap := ctx.Value(ContextDataMapKey)
-	var result *contextData
-
-	if dataMap != nil {
-		if data, ok := dataMap.(map[string]string); ok {
-			result = &contextData{
-				contextId: data[ContextIdKey],
-			}
+	switch ctx.Value(ContextDataMapKey).(type) {
+	case map[string]interface{}, map[string]string:
+		contextId, _ := ContextValue(ctx, ContextIdKey)
+		id, _ := contextId.(string)
+		return &contextData{contextId: id}
+	}
+
+	return nil
+}
+
+// contextDataMap returns the data map stored in ctx to write to it, nil
+// when there is none or when it is a map[string]string stored directly under
+// ContextDataMapKey, which contextDataValues reads
+func contextDataMap(ctx context.Context) map[string]interface{} {
+	data, _ := ctx.Value(ContextDataMapKey).(map[string]interface{})
+	return data
+}
+
+// contextDataValues returns the data map stored in ctx to read it, the
+// values of a map[string]string stored directly included, nil when there is
+// none. It must not be written to.
+func contextDataValues(ctx context.Context) map[string]interface{} {
+	switch data := ctx.Value(ContextDataMapKey).(type) {
+	case map[string]interface{}:
+		return data
+	case map[string]string:
+		values := make(map[string]interface{}, len(data))
+		for key, value := range data {
+			values[key] = value
 		}
+		return values
 	}
 
-	return result
+	return nil
 }
 
 func (l *Log) BuildContextDataAndSetValue(contextId string) (ctx context.Context) {
-	data := make(map[string]string, 0)
+	data := make(map[string]interface{}, 0)
 	data[ContextIdKey] = contextId
 
-	ctx = context.WithValue(context.Background(), ContextDataMapKey, data)
+	ctx = withSteps(context.WithValue(context.Background(), ContextDataMapKey, data))
 
 	return ctx
 }
 
 func (l *Log) AppendContextDataAndSetValue(r *http.Request, contextId string) *http.Request {
-	data := make(map[string]string, 0)
+	data := make(map[string]interface{}, 0)
 	data[ContextIdKey] = contextId
 
-	ctx := context.WithValue(r.Context(), ContextDataMapKey, data)
+	ctx := withSteps(context.WithValue(r.Context(), ContextDataMapKey, data))
 
 	return r.WithContext(ctx)
 }
 
+// SetContextDataAndSetValue stores a data map holding the values of data and
+// contextId in the request context. data is copied, values of other types
+// are added with AppendContextValue.
 func (l *Log) SetContextDataAndSetValue(r *http.Request, data map[string]string, contextId string) *http.Request {
-	if data == nil {
-		data = make(map[string]string, 0)
+	values := make(map[string]interface{}, len(data)+1)
+	for key, value := range data {
+		values[key] = value
 	}
-	data[ContextIdKey] = contextId
+	values[ContextIdKey] = contextId
 
-	ctx := context.WithValue(r.Context(), ContextDataMapKey, data)
+	ctx := withSteps(context.WithValue(r.Context(), ContextDataMapKey, values))
 
 	return r.WithContext(ctx)
 }
@@ -160,113 +552,357 @@ func (l *Log) SetContextDataAndSetValue(r *http.Request, data map[string]string,
 func (l *Log) CreateResponseWrapper(rw http.ResponseWriter) *LoggingResponseWriter {
 	return &LoggingResponseWriter{
 		ResponseWriter: rw,
+		maxBody:        l.config.HTTP.MaxBodyBytes,
 	}
 }
 
+// GetEntry exposes the logrus entry behind the logger. It is kept for
+// compatibility, new code should observe lines as Entry values through
+// Config.OnEntry or a Recorder rather than depend on logrus.
 func (l *Log) GetEntry() *log.Entry {
 	return l.entry
 }
 
 func (l *Log) Infof(ctx context.Context, message string, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.InfoLevel)
-	lp.injectContextDataMap(ctx)
-	l.entry.WithFields(lp.fields).Infof(message, args...)
+	l.outputf(ctx, log.InfoLevel, message, args...)
 }
 
 func (l *Log) Warnf(ctx context.Context, message string, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.WarnLevel)
-	lp.injectContextDataMap(ctx)
-	l.entry.WithFields(lp.fields).Warningf(message, args...)
+	l.outputf(ctx, log.WarnLevel, message, args...)
 }
 
 func (l *Log) Errorf(ctx context.Context, message string, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.ErrorLevel)
-	lp.injectContextDataMap(ctx)
-	l.entry.WithFields(lp.fields).Errorf(message, args...)
+	l.outputf(ctx, log.ErrorLevel, message, args...)
+}
+
+// InfofAt is Infof reporting frame as the caller, for errors and wrappers
+// that recorded where they come from. A zero frame reports the caller as
+// Infof does.
+func (l *Log) InfofAt(ctx context.Context, frame runtime.Frame, message string, args ...interface{}) {
+	l.outputfAt(ctx, log.InfoLevel, frame, message, args...)
+}
+
+// ErrorfAt is Errorf reporting frame as the caller, see InfofAt
+func (l *Log) ErrorfAt(ctx context.Context, frame runtime.Frame, message string, args ...interface{}) {
+	l.outputfAt(ctx, log.ErrorLevel, frame, message, args...)
 }
 
 func (l *Log) Debugf(ctx context.Context, message string, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.DebugLevel)
-	lp.injectContextDataMap(ctx)
-	l.entry.WithFields(lp.fields).Debugf(message, args...)
+	l.outputf(ctx, log.DebugLevel, message, args...)
 }
 
 func (l *Log) Fatalf(ctx context.Context, message string, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.FatalLevel)
-	lp.injectContextDataMap(ctx)
-	l.entry.WithFields(lp.fields).Fatalf(message, args...)
+	l.outputf(ctx, log.FatalLevel, message, args...)
+}
+
+func (l *Log) Tracef(ctx context.Context, message string, args ...interface{}) {
+	l.outputf(ctx, log.TraceLevel, message, args...)
+}
+
+// Panicf is the formatted version of Panic
+func (l *Log) Panicf(ctx context.Context, message string, args ...interface{}) {
+	l.outputf(ctx, log.PanicLevel, message, args...)
 }
 
 func (l *Log) Info(ctx context.Context, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.InfoLevel)
-	lp.injectContextDataMap(ctx)
-	l.entry.WithFields(lp.fields).Info(args...)
+	l.output(ctx, log.InfoLevel, args...)
 }
 
 func (l *Log) Warn(ctx context.Context, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.WarnLevel)
-	lp.injectContextDataMap(ctx)
-	l.entry.WithFields(lp.fields).Warning(args...)
+	l.output(ctx, log.WarnLevel, args...)
 }
 
 func (l *Log) Error(ctx context.Context, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.ErrorLevel)
-	lp.injectContextDataMap(ctx)
-	l.entry.WithFields(lp.fields).Error(args...)
+	l.output(ctx, log.ErrorLevel, args...)
 }
 
 func (l *Log) Debug(ctx context.Context, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.DebugLevel)
-	lp.injectContextDataMap(ctx)
-	l.entry.WithFields(lp.fields).Debug(args...)
+	l.output(ctx, log.DebugLevel, args...)
 }
 
 func (l *Log) Fatal(ctx context.Context, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.FatalLevel)
-	lp.injectContextDataMap(ctx)
-	l.entry.WithFields(lp.fields).Fatal(args...)
+	l.output(ctx, log.FatalLevel, args...)
+}
+
+func (l *Log) Trace(ctx context.Context, args ...interface{}) {
+	l.output(ctx, log.TraceLevel, args...)
 }
 
+// Panic writes args at Panic level then panics, as logrus does: the value
+// recovered is the written *logrus.Entry, not the message. Panic lines are
+// never sampled out nor dropped by Config.BackpressurePolicy, and a line
+// whose formatter or hook panicked raises a *logrus.Entry as well.
+func (l *Log) Panic(ctx context.Context, args ...interface{}) {
+	l.output(ctx, log.PanicLevel, args...)
+}
+
+// Log writes args at a level known at runtime, e.g. read from configuration.
+// An unknown level is logged at Info.
+func (l *Log) Log(ctx context.Context, level log.Level, args ...interface{}) {
+	l.output(ctx, validLevel(level), args...)
+}
+
+// Logf is the formatted version of Log
+func (l *Log) Logf(ctx context.Context, level log.Level, message string, args ...interface{}) {
+	l.outputf(ctx, validLevel(level), message, args...)
+}
+
+// validLevel returns level, or Info when level is not a known level
+func validLevel(level log.Level) log.Level {
+	if level > log.TraceLevel {
+		return log.InfoLevel
+	}
+	return level
+}
+
+// InfoMap logs every dataMap entry as a field, the message is built from
+// args alone, as Info does. The fields injected from the context and the
+// logger, such as context_id and service, win over dataMap entries of the
+// same name.
 func (l *Log) InfoMap(ctx context.Context, dataMap map[string]interface{}, args ...interface{}) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.InfoLevel)
-	lp.injectContextDataMap(ctx)
+	l.outputMap(ctx, log.InfoLevel, dataMap, args...)
+}
 
-	if dataMap != nil {
-		for key, value := range dataMap {
-			lp.fields[key] = value
+// DebugMap is InfoMap at Debug level
+func (l *Log) DebugMap(ctx context.Context, dataMap map[string]interface{}, args ...interface{}) {
+	l.outputMap(ctx, log.DebugLevel, dataMap, args...)
+}
+
+// WarnMap is InfoMap at Warn level
+func (l *Log) WarnMap(ctx context.Context, dataMap map[string]interface{}, args ...interface{}) {
+	l.outputMap(ctx, log.WarnLevel, dataMap, args...)
+}
+
+// ErrorMap is InfoMap at Error level, the fields of an error in args are
+// added as Error does
+func (l *Log) ErrorMap(ctx context.Context, dataMap map[string]interface{}, args ...interface{}) {
+	l.outputMap(ctx, log.ErrorLevel, dataMap, args...)
+}
+
+// LogMap is InfoMap at the given level, Info when level is not a known
+// level, as Log does
+func (l *Log) LogMap(ctx context.Context, level log.Level, dataMap map[string]interface{}, args ...interface{}) {
+	l.outputMap(ctx, validLevel(level), dataMap, args...)
+}
+
+func (l *Log) outputMap(ctx context.Context, level log.Level, dataMap map[string]interface{}, args ...interface{}) {
+	if !l.enabled(ctx, level) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, level).injectErrorFields(args).injectErrorContext(level, args)
+	l.addDataMap(lp, dataMap)
+	l.emit(level, lp, fmt.Sprint(args...))
+}
+
+// InfoMapf is InfoMap with a message formatted from format and args, as
+// Infof does.
+func (l *Log) InfoMapf(ctx context.Context, dataMap map[string]interface{}, format string, args ...interface{}) {
+	if !l.enabled(ctx, log.InfoLevel) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, log.InfoLevel)
+	l.addDataMap(lp, dataMap)
+	l.emit(log.InfoLevel, lp, fmt.Sprintf(format, args...))
+}
+
+// addDataMap adds the dataMap entries whose key is not already injected
+func (l *Log) addDataMap(lp *LogParams, dataMap map[string]interface{}) {
+	for key, value := range dataMap {
+		if _, injected := lp.fields[key]; injected {
+			continue
 		}
+		if _, base := l.entry.Data[key]; base {
+			continue
+		}
+		lp.fields[key] = value
 	}
+}
+
+// InfoSummary logs summary as the message and every detail entry as a field.
+// Unlike InfoMap, whose message is built from arbitrary args, the message is
+// always the given human readable gist while detail stays machine queryable.
+func (l *Log) InfoSummary(ctx context.Context, summary string, detail map[string]interface{}) {
+	l.summary(ctx, log.InfoLevel, summary, detail)
+}
+
+func (l *Log) WarnSummary(ctx context.Context, summary string, detail map[string]interface{}) {
+	l.summary(ctx, log.WarnLevel, summary, detail)
+}
 
-	l.entry.WithFields(lp.fields).Info(args...)
+func (l *Log) ErrorSummary(ctx context.Context, summary string, detail map[string]interface{}) {
+	l.summary(ctx, log.ErrorLevel, summary, detail)
+}
+
+func (l *Log) DebugSummary(ctx context.Context, summary string, detail map[string]interface{}) {
+	l.summary(ctx, log.DebugLevel, summary, detail)
+}
+
+func (l *Log) summary(ctx context.Context, level log.Level, summary string, detail map[string]interface{}) {
+	if !l.enabled(ctx, level) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, level)
+	l.addDataMap(lp, detail)
+	l.emit(level, lp, summary)
 }
 
 func (l *Log) LogRequest(ctx context.Context, r *http.Request) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.InfoLevel)
-	lp.injectContextDataMap(ctx).injectURLPath(ctx, r).injectRequestBody(ctx, r)
-	l.entry.WithFields(lp.fields).Info("Request Body")
+	if !l.enabled(ctx, log.InfoLevel) {
+		return
+	}
+
+	l.emit(log.InfoLevel, l.requestParams(ctx, r, l.requestLogOptions()), "Request Body")
 }
 
 func (l *Log) LogResponse(ctx context.Context, rw *LoggingResponseWriter) {
-	lp := LogParams{fields: log.Fields{}}
-	lp.setCallStackTrace(log.InfoLevel)
-	lp.injectContextDataMap(ctx).injectResponseBody(ctx, rw)
-	l.entry.WithFields(lp.fields).Info("Response Body")
+	if !l.enabled(ctx, log.InfoLevel) {
+		return
+	}
+
+	l.emit(log.InfoLevel, l.responseParams(ctx, rw), "Response Body")
+}
+
+// requestParams holds the fields of a request line
+func (l *Log) requestParams(ctx context.Context, r *http.Request, opts RequestLogOptions) *LogParams {
+	lp := l.newLogParams(ctx, log.InfoLevel)
+	lp.rules = l.httpRedactionRules()
+	lp.injectRequestOptions(ctx, r, opts)
+	if l.config.HTTP.LogTraceSampled {
+		lp.injectTraceSampled(ctx)
+	}
+	return lp
+}
+
+// responseParams holds the fields of a response line
+func (l *Log) responseParams(ctx context.Context, rw *LoggingResponseWriter) *LogParams {
+	lp := l.newLogParams(ctx, log.InfoLevel)
+	lp.rules = l.httpRedactionRules()
+	lp.injectResponseBody(ctx, rw).injectResponseHeaders(rw).injectAnnotations(ctx)
+	if l.config.HTTP.LogTraceSampled {
+		lp.injectTraceSampled(ctx)
+	}
+	return lp
+}
+
+// enabled reports whether a line at level should be written for ctx
+func (l *Log) enabled(ctx context.Context, level log.Level) bool {
+	if !l.levels.enabled(l.category, level) && !requestLevelEnabled(ctx, level) {
+		return false
+	}
+
+	if rate := l.config.ContextSampleRate; rate > 0 && rate < 1 && sampleable(level, false) {
+		if !contextSampled(ctx, rate) {
+			return false
+		}
+	}
+
+	return isSampled(ctx, level, l.config.UnsafeSampleErrors)
+}
+
+func (l *Log) newLogParams(ctx context.Context, level log.Level) *LogParams {
+	lp := &LogParams{fields: log.Fields{}, config: l.config}
+	if l.config.LogRedactedKeys {
+		lp.keys = redactedKeys{}
+	}
+	lp.setCallStackTrace(level, l.callerLevel)
+	lp.injectContextDataMap(ctx).injectTags(ctx).injectStepNum(ctx)
+	if l.config.StrictContext {
+		l.checkContextId(lp)
+	}
+	if l.config.LogContextError {
+		lp.injectContextError(ctx)
+	}
+	if l.config.LogDeadline {
+		lp.injectDeadline(ctx)
+	}
+	return lp
 }
 
-func (lp *LogParams) setCallStackTrace(logLevel log.Level) {
-	if logLevel <= log.ErrorLevel {
+func (l *Log) output(ctx context.Context, level log.Level, args ...interface{}) {
+	if !l.enabled(ctx, level) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, level).injectErrorFields(args).injectErrorContext(level, args)
+	l.emit(level, lp, fmt.Sprint(args...))
+}
+
+func (l *Log) outputf(ctx context.Context, level log.Level, message string, args ...interface{}) {
+	if !l.enabled(ctx, level) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, level).injectErrorFields(args).injectErrorContext(level, args)
+	l.emit(level, lp, fmt.Sprintf(message, args...))
+}
+
+// outputfAt is outputf with the func and file fields taken from frame,
+// whatever the caller level, unless frame is zero
+func (l *Log) outputfAt(ctx context.Context, level log.Level, frame runtime.Frame, message string, args ...interface{}) {
+	if !l.enabled(ctx, level) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, level).injectErrorFields(args).injectErrorContext(level, args)
+	if frame.Function != "" || frame.File != "" {
+		lp.setCaller(&frame)
+	}
+	l.emit(level, lp, fmt.Sprintf(message, args...))
+}
+
+// emit writes the message with the collected fields, every log method ends here
+func (l *Log) emit(level log.Level, lp *LogParams, message string) {
+	keys := lp.keys
+	lp.injectLoggerFields(l.entry.Data)
+	if l.redaction.Load() {
+		redactFields(lp.fields, l.config.RedactionRules, keys)
+	}
+	if l.pii.enabled.Load() {
+		message = l.pii.scrub(lp.fields, message, keys)
+	}
+	if len(keys) > 0 {
+		lp.fields[RedactedKeysKey] = keys.sorted()
+	}
+	if transform := l.fieldTransform.Load(); transform != nil {
+		lp.fields = transformFields(lp.fields, *transform)
+	}
+	lp.injectServiceInfo(l.serviceInfo.Load())
+	if l.config.Sequence {
+		lp.fields[SeqKey] = l.seq.Add(1)
+	}
+	message = truncateMessage(message, l.config.MaxMessageLength)
+	if l.writeLimit.acquire(level) {
+		defer l.writeLimit.release()
+		if lp.mirror != nil {
+			lp.mirror(level, message, lp.fields)
+		}
+		l.write(level, lp.fields, message)
+	}
+
+	if level == log.FatalLevel {
+		l.flushOutputs()
+		l.entry.Logger.Exit(1)
+	}
+}
+
+// injectLoggerFields adds the fields of the logger, e.g. of WithFields, so
+// they are redacted and scrubbed with the others; the fields of the line
+// win, as they do over the logrus entry
+func (lp *LogParams) injectLoggerFields(fields log.Fields) *LogParams {
+	for key, value := range fields {
+		if _, ok := lp.fields[key]; !ok {
+			lp.fields[key] = value
+		}
+	}
+
+	return lp
+}
+
+func (lp *LogParams) setCallStackTrace(logLevel, callerLevel log.Level) {
+	if logLevel <= callerLevel {
 		lp.setCaller(getCaller())
 	}
 }
@@ -277,52 +913,179 @@ func (lp *LogParams) setCaller(caller *runtime.Frame) {
 	}
 
 	funcVal := caller.Function
-	fileVal := fmt.Sprintf("%s:%d", caller.File, caller.Line)
 	if funcVal != "" {
-		lp.fields["func"] = funcVal
+		lp.fields[FuncKey] = funcVal
 	}
+
+	if lp.config != nil && lp.config.SplitCallerLine {
+		lp.fields[FileKey] = caller.File
+		lp.fields[LineKey] = caller.Line
+		return
+	}
+
+	fileVal := fmt.Sprintf("%s:%d", caller.File, caller.Line)
 	if fileVal != "" {
-		lp.fields["file"] = fileVal
+		lp.fields[FileKey] = fileVal
 	}
 }
 
 func (lp *LogParams) injectContextDataMap(ctx context.Context) *LogParams {
-	dataMap := ctx.Value(ContextDataMapKey)
-
-	if dataMap != nil {
-		if data, ok := dataMap.(map[string]string); ok {
-			for key, value := range data {
-				lp.fields[key] = value
-			}
+	switch data := ctx.Value(ContextDataMapKey).(type) {
+	case map[string]interface{}:
+		for key, value := range data {
+			lp.fields[key] = value
+		}
+	case map[string]string:
+		for key, value := range data {
+			lp.fields[key] = value
 		}
 	}
 
 	return lp
 }
 
+// injectContextError reports why ctx is done, the cause is only added when
+// it tells more than the error, e.g. a custom cause or a deadline cause
+func (lp *LogParams) injectContextError(ctx context.Context) *LogParams {
+	err := ctx.Err()
+	if err == nil {
+		return lp
+	}
+
+	lp.fields[ContextErrorKey] = err.Error()
+	if cause := context.Cause(ctx); cause != nil && cause != err {
+		lp.fields[ContextCauseKey] = cause.Error()
+	}
+	return lp
+}
+
+func (lp *LogParams) injectDeadline(ctx context.Context) *LogParams {
+	if deadline, ok := ctx.Deadline(); ok {
+		lp.fields[DeadlineRemainingKey] = time.Until(deadline).Milliseconds()
+	}
+	return lp
+}
+
 func (lp *LogParams) injectURLPath(ctx context.Context, r *http.Request) *LogParams {
 	lp.fields[PathKey] = r.Host + r.URL.Path
 	return lp
 }
 
+func (lp *LogParams) injectUserAgent(r *http.Request) *LogParams {
+	if userAgent := r.UserAgent(); userAgent != "" {
+		lp.fields[UserAgentKey] = truncateMessage(userAgent, maximumUserAgentLength)
+	}
+	if referer := r.Referer(); referer != "" {
+		lp.fields[RefererKey] = referer
+	}
+	return lp
+}
+
+// injectTraceSampled tells whether the distributed trace of ctx is sampled,
+// nothing is added when ctx carries no trace
+func (lp *LogParams) injectTraceSampled(ctx context.Context) *LogParams {
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		lp.fields[SampledKey] = spanContext.IsSampled()
+	}
+	return lp
+}
+
 func (lp *LogParams) injectRequestBody(ctx context.Context, r *http.Request) *LogParams {
-	buf, _ := ioutil.ReadAll(r.Body)
-	r.Body.Close()
-	r.Body = ioutil.NopCloser(bytes.NewBuffer(buf))
+	body := bodyBuffer(ctx)
+	max := lp.config.HTTP.MaxBodyBytes
+	truncated := false
+	if max > 0 {
+		// one byte more tells whether the body is longer
+		body.ReadFrom(io.LimitReader(r.Body, int64(max)+1))
+		r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(body.Bytes()), r.Body), Closer: r.Body}
+		truncated = body.Len() > max
+	} else {
+		body.ReadFrom(r.Body)
+		r.Body.Close()
+		r.Body = ioutil.NopCloser(body)
+	}
+
+	captured := body.String()
+	if truncated {
+		captured = cutBody(body.Bytes(), max)
+		lp.fields[RequestTruncatedKey] = true
+		if r.ContentLength >= 0 {
+			lp.fields[RequestLengthKey] = r.ContentLength
+		}
+	}
+
+	if lp.config.HTTP.Base64Bodies && !utf8.ValidString(captured) {
+		lp.fields[RequestB64Key] = base64.StdEncoding.EncodeToString([]byte(captured))
+		lp.fields[BodyEncodingKey] = base64Encoding
+		return lp
+	}
 
-	lp.fields[RequestKey] = fmt.Sprintf("%q", r.Body)
+	lp.fields[RequestKey] = fmt.Sprintf("{%q}", lp.redactCapturedBody(captured, truncated))
 	return lp
 }
 
 func (lp *LogParams) injectResponseBody(ctx context.Context, rw *LoggingResponseWriter) *LogParams {
 	lp.fields[ResponseCodeKey] = rw.Status
-	lp.fields[ResponseKey] = rw.Body
+	if bodySampled(ctx) {
+		truncated := rw.BodyLength > len(rw.Body)
+		if lp.config.HTTP.Base64Bodies && !utf8.ValidString(rw.Body) {
+			lp.fields[ResponseB64Key] = base64.StdEncoding.EncodeToString([]byte(rw.Body))
+			lp.fields[BodyEncodingKey] = base64Encoding
+		} else {
+			lp.fields[ResponseKey] = lp.redactCapturedBody(rw.Body, truncated)
+		}
+		if truncated {
+			lp.fields[ResponseTruncatedKey] = true
+			lp.fields[ResponseLengthKey] = rw.BodyLength
+		}
+	}
+	if rw.Panicked {
+		lp.fields[PanicKey] = true
+	}
+	return lp
+}
+
+// injectResponseHeaders adds the headers set by the handler that are listed
+// in HTTPConfig.ResponseHeaders, Set-Cookie values redacted
+func (lp *LogParams) injectResponseHeaders(rw *LoggingResponseWriter) *LogParams {
+	names := lp.config.HTTP.ResponseHeaders
+	if len(names) == 0 {
+		return lp
+	}
+
+	header := rw.Header()
+	headers := make(map[string]string, len(names))
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(name)
+		values := header.Values(name)
+		switch {
+		case len(values) == 0:
+			continue
+		case name == "Set-Cookie":
+			headers[name] = RedactedValue
+		default:
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+	redactHeaders(headers, lp.rules, lp.keys)
+	lp.fields[ResponseHeadersKey] = headers
 	return lp
 }
 
 type LoggingResponseWriter struct {
 	Status int
 	Body   string
+
+	// BodyLength is the length of the write captured in Body, longer than
+	// Body when HTTPConfig.MaxBodyBytes cut it
+	BodyLength int
+
+	// Panicked is set by Middleware when the handler panicked
+	Panicked bool
+
+	// maxBody is HTTPConfig.MaxBodyBytes of the logger that built it
+	maxBody int
+
 	http.ResponseWriter
 }
 
@@ -332,10 +1095,71 @@ func (w *LoggingResponseWriter) WriteHeader(code int) {
 }
 
 func (w *LoggingResponseWriter) Write(body []byte) (int, error) {
-	w.Body = string(body)
+	if w.Status == 0 {
+		w.Status = http.StatusOK
+	}
+	w.BodyLength = len(body)
+	if w.maxBody > 0 && len(body) > w.maxBody {
+		w.Body = cutBody(body, w.maxBody)
+	} else {
+		w.Body = string(body)
+	}
 	return w.ResponseWriter.Write(body)
 }
 
+// Flush sends the buffered data to the client, for streamed responses such
+// as server-sent events. It does nothing when the wrapped ResponseWriter
+// cannot flush.
+func (w *LoggingResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.Status == 0 {
+			w.Status = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over to the handler, e.g. for a WebSocket
+// upgrade. It returns http.ErrNotSupported when the wrapped ResponseWriter
+// cannot be hijacked, e.g. on HTTP/2.
+func (w *LoggingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hijacker.Hijack()
+}
+
+// Push starts an HTTP/2 server push. It returns http.ErrNotSupported when
+// the wrapped ResponseWriter cannot push.
+func (w *LoggingResponseWriter) Push(target string, opts *http.PushOptions) error {
+	pusher, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return pusher.Push(target, opts)
+}
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController
+func (w *LoggingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// truncateMessage cuts message to at most max bytes, on a rune boundary,
+// and marks it as truncated. A max of zero or less keeps the message whole.
+func truncateMessage(message string, max int) string {
+	if max <= 0 || len(message) <= max {
+		return message
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+
+	return message[:cut] + truncatedSuffix
+}
+
 // getCaller retrieves the name of the first non this package calling function
 func getCaller() *runtime.Frame {
 
@@ -1,7 +1,8 @@
 package log
 
 import (
-	"time"
+	"io/ioutil"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 	logrusTest "github.com/sirupsen/logrus/hooks/test"
@@ -15,11 +16,94 @@ import (
  */
 func NewLoggerWithTestHook(service string) (Logger, *logrusTest.Hook) {
 	logger := log.New()
+	return newLog(logger, Config{Service: service}), logrusTest.NewLocal(logger)
+}
+
+// Recorder captures every entry written by a logger, it is safe for
+// concurrent use so it can observe handlers under test.
+type Recorder struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+// TestingT is the part of *testing.T used by the Recorder assertions.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+/**
+ * return a Log object that writes nowhere and a Recorder
+ *		capturing each of its entries, to assert on levels
+ * 		and counts of what the code under test logged.
+ */
+func NewLoggerWithRecorder(service string) (Logger, *Recorder) {
+	logger := log.New()
+	l := newLog(logger, Config{Service: service})
+	logger.SetOutput(ioutil.Discard)
+
+	recorder := &Recorder{}
+	logger.AddHook(recorder)
+	return l, recorder
+}
+
+func (r *Recorder) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (r *Recorder) Fire(entry *log.Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, newEntry(entry))
+	return nil
+}
+
+// Entries returns a copy of the entries recorded so far
+func (r *Recorder) Entries() []Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]Entry, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// Count returns the number of entries recorded at exactly level
+func (r *Recorder) Count(level Level) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, entry := range r.entries {
+		if entry.Level == level {
+			count++
+		}
+	}
+	return count
+}
+
+// AssertNoLevel fails t when an entry at level or more severe was recorded,
+// e.g. AssertNoLevel(t, ErrorLevel) asserts nothing failed loudly.
+func (r *Recorder) AssertNoLevel(t TestingT, level Level) bool {
+	t.Helper()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, entry := range r.entries {
+		if entry.Level <= level {
+			t.Errorf("unexpected %s entry logged: %q", entry.Level, entry.Message)
+			return false
+		}
+	}
+	return true
+}
+
+// Reset forgets every entry recorded so far
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	logger.SetFormatter(&log.JSONFormatter{
-		TimestampFormat: time.RFC3339Nano,
-	})
-	entry := log.NewEntry(logger)
-	entry = entry.WithField("service", service)
-	return &Log{entry}, logrusTest.NewLocal(logger)
+	r.entries = nil
 }
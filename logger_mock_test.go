@@ -0,0 +1,61 @@
+package log
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+type fakeT struct {
+	errors []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorderCountsLevels(t *testing.T) {
+	recordedLogger, recorder := NewLoggerWithRecorder(sampleString)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recordedLogger.Info(sampleContext, sampleString)
+		}()
+	}
+	wg.Wait()
+
+	handler := recordedLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recordedLogger.Warn(r.Context(), "slow")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)))
+
+	assert.Equal(t, 12, recorder.Count(log.InfoLevel))
+	assert.Equal(t, 1, recorder.Count(WarnLevel))
+	assert.True(t, recorder.AssertNoLevel(t, ErrorLevel))
+
+	recordedLogger.Error(sampleContext, "failed")
+	entries := recorder.Entries()
+	last := entries[len(entries)-1]
+	assert.Equal(t, ErrorLevel, last.Level)
+	assert.Equal(t, "failed", last.Message)
+	assert.Equal(t, "11", last.Fields[ContextIdKey])
+	assert.False(t, last.Time.IsZero())
+
+	fake := &fakeT{}
+	assert.False(t, recorder.AssertNoLevel(fake, log.ErrorLevel))
+	assert.Equal(t, 1, len(fake.errors))
+
+	recorder.Reset()
+	assert.Equal(t, 0, len(recorder.Entries()))
+}
@@ -3,9 +3,17 @@ package log
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"github.com/c2fo/testify/assert"
+	"github.com/sirupsen/logrus"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"regexp"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 )
@@ -131,9 +139,345 @@ func TestNoCollisionWhenBuildContextData(t *testing.T) {
 
 	ctx := logger.BuildContextDataAndSetValue(randomID)
 	newCtx := context.WithValue(ctx, thisKey, thisKeyValue)
-	contextDataFromLogger := newCtx.Value(ContextDataMapKey).(map[string]string)
+	contextDataFromLogger := newCtx.Value(ContextDataMapKey).(map[string]interface{})
 
 	// Ensure there is no collision
 	assert.Equal(t, thisKeyValue, newCtx.Value(thisKey))
 	assert.Equal(t, randomID, contextDataFromLogger[ContextIdKey])
 }
+
+func TestSplitCallerLine(t *testing.T) {
+	splitLogger := NewLoggerWithConfig(Config{Service: sampleString, SplitCallerLine: true})
+	hook := logrusTest.NewLocal(splitLogger.GetEntry().Logger)
+	splitLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	splitLogger.Error(sampleContext, sampleString)
+
+	entry := hook.LastEntry()
+	assert.NotNil(t, entry)
+	assert.IsType(t, 0, entry.Data[LineKey])
+	assert.False(t, strings.Contains(entry.Data[FileKey].(string), ":"))
+}
+
+func TestInfoMapf(t *testing.T) {
+	mapLogger, hook := NewLoggerWithTestHook(sampleString)
+	mapLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	mapLogger.InfoMapf(sampleContext, map[string]interface{}{
+		"order_id":   7,
+		ContextIdKey: "spoofed",
+		"service":    "spoofed",
+	}, "order %d placed", 7)
+
+	entry := hook.LastEntry()
+	assert.Equal(t, "order 7 placed", entry.Message)
+	assert.Equal(t, 7, entry.Data["order_id"])
+	assert.Equal(t, "11", entry.Data[ContextIdKey])
+	assert.Equal(t, sampleString, entry.Data["service"])
+}
+
+func TestLevelMaps(t *testing.T) {
+	mapLogger, hook := NewLoggerWithTestHook(sampleString)
+	mapLogger.GetEntry().Logger.Out = ioutil.Discard
+	mapLogger.SetLevel(logrus.DebugLevel)
+	dataMap := map[string]interface{}{"order_id": 7}
+
+	mapLogger.DebugMap(sampleContext, dataMap, "debug")
+	mapLogger.WarnMap(sampleContext, dataMap, "warn")
+	mapLogger.LogMap(sampleContext, logrus.Level(42), dataMap, "unknown")
+
+	err := WrapError(errors.New("declined"), map[string]interface{}{"gateway": "stripe"})
+	mapLogger.ErrorMap(sampleContext, dataMap, err)
+
+	entries := hook.AllEntries()
+	assert.Equal(t, 4, len(entries))
+	for i, level := range []logrus.Level{logrus.DebugLevel, logrus.WarnLevel, logrus.InfoLevel, logrus.ErrorLevel} {
+		assert.Equal(t, level, entries[i].Level)
+		assert.Equal(t, 7, entries[i].Data["order_id"])
+		assert.Equal(t, "11", entries[i].Data[ContextIdKey])
+	}
+	assert.Equal(t, "stripe", entries[3].Data["gateway"])
+
+	mapLogger.SetLevel(logrus.InfoLevel)
+	mapLogger.DebugMap(sampleContext, dataMap, "filtered")
+	assert.Equal(t, 4, len(hook.AllEntries()))
+}
+
+func TestSummaries(t *testing.T) {
+	summaryLogger, hook := NewLoggerWithTestHook(sampleString)
+	summaryLogger.GetEntry().Logger.Out = ioutil.Discard
+	summaryLogger.SetLevel(logrus.DebugLevel)
+	detail := map[string]interface{}{
+		"order_id":   7,
+		"items":      []string{"a", "b"},
+		ContextIdKey: "spoofed",
+		"service":    "spoofed",
+	}
+
+	summaryLogger.InfoSummary(sampleContext, "order placed", detail)
+	summaryLogger.WarnSummary(sampleContext, "order placed", detail)
+	summaryLogger.ErrorSummary(sampleContext, "order placed", detail)
+	summaryLogger.DebugSummary(sampleContext, "order placed", detail)
+
+	entries := hook.AllEntries()
+	assert.Equal(t, 4, len(entries))
+	for i, level := range []logrus.Level{logrus.InfoLevel, logrus.WarnLevel, logrus.ErrorLevel, logrus.DebugLevel} {
+		assert.Equal(t, level, entries[i].Level)
+		assert.Equal(t, "order placed", entries[i].Message)
+		assert.Equal(t, 7, entries[i].Data["order_id"])
+		assert.Equal(t, []string{"a", "b"}, entries[i].Data["items"])
+		assert.Equal(t, "11", entries[i].Data[ContextIdKey])
+		assert.Equal(t, sampleString, entries[i].Data["service"])
+	}
+
+	summaryLogger.SetLevel(logrus.InfoLevel)
+	summaryLogger.DebugSummary(sampleContext, "filtered", detail)
+	assert.Equal(t, 4, len(hook.AllEntries()))
+}
+
+func TestTraceAndPanic(t *testing.T) {
+	levelLogger, hook := NewLoggerWithTestHook(sampleString)
+	levelLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	levelLogger.Tracef(sampleContext, "frame %d", 1)
+	assert.Equal(t, 0, len(hook.AllEntries()))
+
+	levelLogger.SetLevel(logrus.TraceLevel)
+	levelLogger.Trace(sampleContext, "frame")
+	assert.Equal(t, logrus.TraceLevel, hook.LastEntry().Level)
+	assert.Equal(t, "11", hook.LastEntry().Data[ContextIdKey])
+
+	defer func() {
+		entry, ok := recover().(*logrus.Entry)
+		assert.True(t, ok)
+		assert.Equal(t, "rethrown 7", entry.Message)
+		assert.Equal(t, logrus.PanicLevel, hook.LastEntry().Level)
+		assert.Equal(t, "11", hook.LastEntry().Data[ContextIdKey])
+	}()
+	levelLogger.Panicf(sampleContext, "rethrown %d", 7)
+	t.Fatal("Panicf did not panic")
+}
+
+func TestInfofAt(t *testing.T) {
+	atLogger, hook := NewLoggerWithTestHook(sampleString)
+	atLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	frame := runtime.Frame{Function: "example.com/orders.Place", File: "/src/orders/place.go", Line: 42}
+	atLogger.InfofAt(sampleContext, frame, "placed %d", 3)
+
+	entry := hook.LastEntry()
+	assert.Equal(t, "placed 3", entry.Message)
+	assert.Equal(t, "example.com/orders.Place", entry.Data[FuncKey])
+	assert.Equal(t, "/src/orders/place.go:42", entry.Data[FileKey])
+
+	// a zero frame reports the caller as Errorf does
+	atLogger.ErrorfAt(sampleContext, runtime.Frame{}, sampleString)
+	assert.NotEqual(t, "example.com/orders.Place", hook.LastEntry().Data[FuncKey])
+	assert.NotNil(t, hook.LastEntry().Data[FileKey])
+}
+
+func TestTruncateMessage(t *testing.T) {
+	assert.Equal(t, sampleString, truncateMessage(sampleString, 0))
+	assert.Equal(t, sampleString, truncateMessage(sampleString, len(sampleString)))
+	assert.Equal(t, "some"+truncatedSuffix, truncateMessage(sampleString, 4))
+
+	// never cut inside a multi-byte rune
+	assert.Equal(t, "a"+truncatedSuffix, truncateMessage("aé", 2))
+}
+
+func TestLogContextErrorWithCause(t *testing.T) {
+	causeLogger := NewLoggerWithConfig(Config{Service: sampleString, LogContextError: true})
+	causeLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(causeLogger.GetEntry().Logger)
+
+	ctx, cancel := context.WithCancelCause(sampleContext)
+	causeLogger.Info(ctx, "running")
+	_, ok := hook.LastEntry().Data[ContextErrorKey]
+	assert.False(t, ok)
+
+	cancel(errors.New("client went away"))
+	causeLogger.Info(ctx, "canceled")
+	assert.Equal(t, context.Canceled.Error(), hook.LastEntry().Data[ContextErrorKey])
+	assert.Equal(t, "client went away", hook.LastEntry().Data[ContextCauseKey])
+}
+
+func TestLogDeadline(t *testing.T) {
+	deadlineLogger := NewLoggerWithConfig(Config{Service: sampleString, LogDeadline: true})
+	deadlineLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(deadlineLogger.GetEntry().Logger)
+
+	deadlineLogger.Info(sampleContext, "no deadline")
+	_, ok := hook.LastEntry().Data[DeadlineRemainingKey]
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(sampleContext, time.Minute)
+	defer cancel()
+	deadlineLogger.Info(ctx, "with deadline")
+	remaining := hook.LastEntry().Data[DeadlineRemainingKey].(int64)
+	assert.True(t, remaining > 59000 && remaining <= 60000)
+}
+
+func TestLogWithDynamicLevel(t *testing.T) {
+	levelLogger, recorder := NewLoggerWithRecorder(sampleString)
+
+	levelLogger.Log(sampleContext, logrus.WarnLevel, sampleString)
+	levelLogger.Logf(sampleContext, logrus.Level(42), "%s", sampleString)
+
+	assert.Equal(t, 1, recorder.Count(logrus.WarnLevel))
+	assert.Equal(t, 1, recorder.Count(logrus.InfoLevel))
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	logger, err := New(Config{Service: sampleString})
+	assert.Nil(t, err)
+	assert.NotNil(t, logger)
+
+	_, err = New(Config{Service: sampleString, Outputs: []Output{{}}})
+	assert.Equal(t, "log: Config.Outputs[0].Writer is nil", err.Error())
+
+	_, err = New(Config{Service: sampleString, HTTP: HTTPConfig{BodySampleRate: -1}})
+	assert.NotNil(t, err)
+
+	// an unkeyed correlation hash could be precomputed
+	_, err = New(Config{Service: sampleString, HTTP: HTTPConfig{CorrelationHash: &CorrelationHash{Attributes: ClientAttributes}}})
+	assert.Equal(t, "log: Config.HTTP.CorrelationHash needs a Key or a Hash", err.Error())
+	_, err = New(Config{Service: sampleString, HTTP: HTTPConfig{CorrelationHash: &CorrelationHash{
+		Attributes: ClientAttributes,
+		Hash:       func(attributes []string) string { return "" },
+	}}})
+	assert.Nil(t, err)
+}
+
+func TestMustNewPanicsOnInvalidConfig(t *testing.T) {
+	defer func() {
+		err, ok := recover().(error)
+		assert.True(t, ok)
+		assert.Equal(t, "log: Config.MaxMessageLength is negative: -1", err.Error())
+	}()
+
+	MustNew(Config{Service: sampleString, MaxMessageLength: -1})
+	t.Fatal("MustNew did not panic")
+}
+
+func TestCallerLevelIndependentOfEmission(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.DebugLevel)
+	callerLogger := newLog(logger, Config{Service: sampleString, CallerLevel: logrus.WarnLevel})
+	logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(logger)
+
+	hasCaller := func() bool {
+		_, ok := hook.LastEntry().Data[FuncKey]
+		return ok
+	}
+
+	callerLogger.Debug(sampleContext, sampleString)
+	assert.Equal(t, logrus.DebugLevel, hook.LastEntry().Level)
+	assert.False(t, hasCaller())
+
+	callerLogger.Info(sampleContext, sampleString)
+	assert.False(t, hasCaller())
+
+	callerLogger.Warn(sampleContext, sampleString)
+	assert.True(t, hasCaller())
+
+	callerLogger.Error(sampleContext, sampleString)
+	assert.True(t, hasCaller())
+}
+
+func TestCallerLevelDefaults(t *testing.T) {
+	assert.Equal(t, logrus.ErrorLevel, (&Config{}).callerLevel())
+	assert.Equal(t, logrus.TraceLevel, (&Config{ReportCaller: true, CallerLevel: logrus.WarnLevel}).callerLevel())
+}
+
+func TestEnvField(t *testing.T) {
+	t.Setenv(EnvVariable, "staging")
+
+	envLogger := NewLoggerWithConfig(Config{Service: sampleString})
+	envLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(envLogger.GetEntry().Logger)
+	envLogger.Info(sampleContext, sampleString)
+	assert.Equal(t, "staging", hook.LastEntry().Data[EnvKey])
+
+	configured := NewLoggerWithConfig(Config{Service: sampleString, Env: "production"})
+	assert.Equal(t, "production", configured.GetEntry().Data[EnvKey])
+
+	t.Setenv(EnvVariable, "")
+	unset := NewLoggerWithConfig(Config{Service: sampleString})
+	_, ok := unset.GetEntry().Data[EnvKey]
+	assert.False(t, ok)
+}
+
+func TestSequenceSharedByDerivedLoggers(t *testing.T) {
+	seqLogger := NewLoggerWithConfig(Config{Service: sampleString, Sequence: true})
+	seqLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(seqLogger.GetEntry().Logger)
+
+	seqLogger.Info(sampleContext, sampleString)
+	seqLogger.Named("db").Info(sampleContext, sampleString)
+	seqLogger.WithContext(sampleContext).Warn(sampleString)
+
+	entries := hook.AllEntries()
+	for i, entry := range entries {
+		assert.Equal(t, uint64(i+1), entry.Data[SeqKey])
+	}
+}
+
+func TestConfigSnapshot(t *testing.T) {
+	configLogger := NewLoggerWithConfig(Config{Service: sampleString, ReportCaller: true, Sequence: true})
+	configLogger.GetEntry().Logger.Out = ioutil.Discard
+	configLogger.AddPIIPattern("card", regexp.MustCompile(`\d{4}-\d{4}`))
+	configLogger.SetLevel(logrus.DebugLevel)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			configLogger.Info(sampleContext, sampleString)
+		}
+	}()
+	snapshot := configLogger.Config()
+	<-done
+
+	assert.Equal(t, "debug", snapshot.Level)
+	assert.Equal(t, "*logrus.JSONFormatter", snapshot.Formatter)
+	assert.True(t, snapshot.ReportCaller)
+	assert.Equal(t, []string{"report_caller", "sequence"}, snapshot.Features)
+	assert.Equal(t, "card", snapshot.PIIPatterns[len(snapshot.PIIPatterns)-1])
+
+	// names only, the patterns stay out of the snapshot
+	b, _ := json.Marshal(snapshot)
+	assert.False(t, strings.Contains(string(b), `\\d{4}`))
+}
+
+func TestLogInitialization(t *testing.T) {
+	var out bytes.Buffer
+	_, err := New(Config{
+		Service:              sampleString,
+		Outputs:              []Output{{Writer: &out}},
+		Sequence:             true,
+		ErrorContextTemplate: "secret-template {{.user}}",
+		SensitiveClaims:      []string{"secret-claim"},
+		HTTP:                 HTTPConfig{RequestIdHeader: "X-Secret-Header"},
+		LogInitialization:    true,
+	})
+	assert.Nil(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 1, len(lines))
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &line))
+	assert.Equal(t, "logger initialized", line["msg"])
+	assert.Equal(t, "info", line["level"])
+	assert.Equal(t, "info", line["log_level"])
+	assert.Equal(t, "log.nopFormatter", strings.TrimPrefix(line["formatter"].(string), "*"))
+	assert.Equal(t, []interface{}{"error_context_template", "sequence", "multiple_outputs", "request_id_header"}, line["features"])
+	assert.False(t, strings.Contains(lines[0], "secret"))
+
+	// off by default
+	out.Reset()
+	_, err = New(Config{Service: sampleString, Outputs: []Output{{Writer: &out}}})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, out.Len())
+}
@@ -0,0 +1,144 @@
+// Package logproto logs protobuf messages as their protojson form. Import it
+// for its side effect to have every proto.Message field value serialized
+// with protojson instead of Go's struct printing:
+//
+//	import _ "github.com/muhammad-fakhri/log/logproto"
+//
+// Fields marked with the debug_redact option in the .proto file are logged
+// as log.RedactedValue. The package lives apart so services without
+// protobuf do not depend on it.
+package logproto
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/muhammad-fakhri/log"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// MessageKey holds the message logged by InfoProto
+	MessageKey = "proto"
+
+	// TypeKey holds the full name of the message logged by InfoProto
+	TypeKey = "proto_type"
+)
+
+func init() {
+	log.RegisterInterfaceSerializer(reflect.TypeOf((*proto.Message)(nil)).Elem(), func(value interface{}) interface{} {
+		return Serialize(value.(proto.Message))
+	})
+}
+
+// InfoProto logs msg at Info under the "proto" key, with its full name
+// under "proto_type" and note as the message.
+func InfoProto(ctx context.Context, logger log.Logger, msg proto.Message, note string) {
+	logger.InfoMap(ctx, map[string]interface{}{
+		MessageKey: msg,
+		TypeKey:    string(msg.ProtoReflect().Descriptor().FullName()),
+	}, note)
+}
+
+// Serialize returns the protojson form of msg with its debug_redact fields
+// masked, as a value the JSON formatters embed as an object. A message that
+// cannot be marshaled is returned as its error text.
+func Serialize(msg proto.Message) interface{} {
+	encoded, err := protojson.Marshal(msg)
+	if err != nil {
+		return err.Error()
+	}
+
+	m := msg.ProtoReflect()
+	if !hasRedactedField(m.Descriptor(), map[protoreflect.FullName]bool{}) {
+		return json.RawMessage(encoded)
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(encoded, &object); err != nil {
+		return json.RawMessage(encoded)
+	}
+	redactMessage(m, object)
+
+	return object
+}
+
+// redactMessage masks in object, the protojson form of m, the fields of m
+// and of its nested messages marked with debug_redact
+func redactMessage(m protoreflect.Message, object map[string]interface{}) {
+	m.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		name := field.JSONName()
+		if _, ok := object[name]; !ok {
+			name = string(field.Name())
+		}
+		if _, ok := object[name]; !ok {
+			return true
+		}
+
+		if redacted(field) {
+			object[name] = log.RedactedValue
+			return true
+		}
+		if field.Message() == nil {
+			return true
+		}
+
+		switch {
+		case field.IsList():
+			items, _ := object[name].([]interface{})
+			list := value.List()
+			for i := 0; i < list.Len() && i < len(items); i++ {
+				if nested, ok := items[i].(map[string]interface{}); ok {
+					redactMessage(list.Get(i).Message(), nested)
+				}
+			}
+		case field.IsMap():
+			entries, _ := object[name].(map[string]interface{})
+			value.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+				if nested, ok := entries[key.String()].(map[string]interface{}); ok {
+					redactMessage(value.Message(), nested)
+				}
+				return true
+			})
+		default:
+			if nested, ok := object[name].(map[string]interface{}); ok {
+				redactMessage(value.Message(), nested)
+			}
+		}
+		return true
+	})
+}
+
+// hasRedactedField reports whether a message of type d can hold a field
+// marked with debug_redact
+func hasRedactedField(d protoreflect.MessageDescriptor, seen map[protoreflect.FullName]bool) bool {
+	if seen[d.FullName()] {
+		return false
+	}
+	seen[d.FullName()] = true
+
+	fields := d.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if redacted(field) {
+			return true
+		}
+		if field.IsMap() {
+			field = field.MapValue()
+		}
+		if field.Message() != nil && hasRedactedField(field.Message(), seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func redacted(field protoreflect.FieldDescriptor) bool {
+	options, ok := field.Options().(*descriptorpb.FieldOptions)
+	return ok && options.GetDebugRedact()
+}
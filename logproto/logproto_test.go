@@ -0,0 +1,77 @@
+package logproto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	"github.com/muhammad-fakhri/log"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// userDescriptor describes
+//
+//	message User {
+//	  string name = 1;
+//	  string password = 2 [debug_redact = true];
+//	  User manager = 3;
+//	}
+func userDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("user.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("name"), JsonName: proto.String("name"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+				{Name: proto.String("password"), JsonName: proto.String("password"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(), Options: &descriptorpb.FieldOptions{DebugRedact: proto.Bool(true)}},
+				{Name: proto.String("manager"), JsonName: proto.String("manager"), Number: proto.Int32(3), Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".test.User"), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+			},
+		}},
+	}, nil)
+	assert.Nil(t, err)
+
+	return file.Messages().Get(0)
+}
+
+func newUser(d protoreflect.MessageDescriptor, name, password string) *dynamicpb.Message {
+	user := dynamicpb.NewMessage(d)
+	user.Set(d.Fields().ByName("name"), protoreflect.ValueOfString(name))
+	user.Set(d.Fields().ByName("password"), protoreflect.ValueOfString(password))
+	return user
+}
+
+func TestInfoProtoRedactsSensitiveFields(t *testing.T) {
+	d := userDescriptor(t)
+	user := newUser(d, "fakhri", "secret")
+	user.Set(d.Fields().ByName("manager"), protoreflect.ValueOfMessage(newUser(d, "boss", "hidden")))
+
+	var buf bytes.Buffer
+	logger := log.NewLogger("svc")
+	logger.GetEntry().Logger.Out = &buf
+
+	InfoProto(context.Background(), logger, user, "user loaded")
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "user loaded", line["msg"])
+	assert.Equal(t, "test.User", line[TypeKey])
+	assert.Equal(t, map[string]interface{}{
+		"name":     "fakhri",
+		"password": log.RedactedValue,
+		"manager":  map[string]interface{}{"name": "boss", "password": log.RedactedValue},
+	}, line[MessageKey])
+}
+
+func TestSerializeWithoutRedaction(t *testing.T) {
+	encoded, err := json.Marshal(Serialize(&descriptorpb.FieldOptions{DebugRedact: proto.Bool(true)}))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"debugRedact":true}`, string(encoded))
+}
@@ -0,0 +1,85 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultLokiLabels are low cardinality keys fit to be Loki stream labels
+var DefaultLokiLabels = []string{"service", "level", "env"}
+
+// LokiFormatter renders every entry as a Loki push API payload, one per
+// line, ready to be POSTed to /loki/api/v1/push or forwarded by a collector.
+// The Labels keys become the stream labels, the remaining fields are
+// rendered by Line as the log line.
+type LokiFormatter struct {
+	// Labels lists the keys moved to the stream labels, "level" being the
+	// entry level. DefaultLokiLabels when nil. Keys absent from an entry
+	// are skipped.
+	Labels []string
+
+	// Line renders the log line, a JSONFormatter when nil
+	Line log.Formatter
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (f *LokiFormatter) Format(entry *log.Entry) ([]byte, error) {
+	labelKeys := f.Labels
+	if labelKeys == nil {
+		labelKeys = DefaultLokiLabels
+	}
+
+	labels := make(map[string]string, len(labelKeys))
+	data := make(log.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		data[key] = value
+	}
+	for _, key := range labelKeys {
+		if key == "level" {
+			labels[key] = entry.Level.String()
+			continue
+		}
+		if value, ok := data[key]; ok {
+			labels[key] = fmt.Sprint(value)
+			delete(data, key)
+		}
+	}
+
+	lineFormatter := f.Line
+	if lineFormatter == nil {
+		lineFormatter = &JSONFormatter{}
+	}
+
+	lineEntry := *entry
+	lineEntry.Data = data
+	lineEntry.Buffer = nil
+	line, err := lineFormatter.Format(&lineEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(lokiPush{Streams: []lokiStream{{
+		Stream: labels,
+		Values: [][2]string{{
+			strconv.FormatInt(entry.Time.UnixNano(), 10),
+			string(bytes.TrimRight(line, "\n")),
+		}},
+	}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Loki payload, %v", err)
+	}
+
+	return append(payload, '\n'), nil
+}
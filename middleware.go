@@ -0,0 +1,296 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Middleware wires the per request logging around next, in place of
+// AppendContextDataAndSetValue, CreateResponseWrapper, LogRequest and
+// LogResponse called by hand: it stores a context id in the request
+// context, logs the request, wraps the ResponseWriter and logs the response
+// once next returns, in the lines chosen by HTTPConfig.AccessLogStyle.
+//
+//	http.ListenAndServe(":8080", logger.Middleware(mux))
+//
+// The context id and the fields already in the request context, e.g. set by
+// an outer middleware, are kept, a new id is generated otherwise.
+//
+// When next panics, the panic is logged at Error with its stack, the
+// response captured so far is logged with a "panic" field set to true, and
+// the panic is raised again for the server or an outer recovery to handle.
+//
+// The request body logged is replayed to next from a pooled buffer, reused
+// once the request is complete, so next must not read it after returning.
+//
+// When HTTPConfig.RequestIdHeader is set, the context id is read from and
+// echoed in that header, e.g. X-Request-ID.
+// When HTTPConfig.ContextIdTrailer is set, the context id is also read from
+// and written to that HTTP trailer, see its documentation for the limits.
+// When HTTPConfig.LevelHeader is set, an authorized request can lower the
+// level of its own lines.
+func (l *Log) Middleware(next http.Handler) http.Handler {
+	return l.middleware(next, l.requestLogOptions())
+}
+
+func (l *Log) middleware(next http.Handler, opts RequestLogOptions) http.Handler {
+	name := ""
+	if l.config.HTTP.LogHandlerName {
+		name = handlerName(next)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trailer := l.config.HTTP.ContextIdTrailer
+
+		contextId := ""
+		if trailer != "" {
+			contextId = readContextIdTrailer(r, trailer, l.config.HTTP.MaxBodyBytes)
+			w.Header().Add("Trailer", trailer)
+		}
+		requestIdHeader := l.config.HTTP.RequestIdHeader
+		if contextId == "" && requestIdHeader != "" {
+			contextId = readRequestIdHeader(r, requestIdHeader)
+		}
+		if data := l.getContextData(r.Context()); contextId == "" && data != nil {
+			contextId = data.contextId
+		}
+		if contextId == "" {
+			contextId = newContextId()
+		}
+		if requestIdHeader != "" {
+			w.Header().Set(requestIdHeader, contextId)
+		}
+
+		// the fields of an outer data map are kept, on a copy the request
+		// can write to, and so are its steps while the id is the same
+		_, counted := r.Context().Value(stepsKey).(*atomic.Int64)
+		if outer := l.getContextData(r.Context()); !counted || outer == nil || outer.contextId != contextId {
+			r = r.WithContext(withSteps(r.Context()))
+		}
+		data := copyDataMap(r.Context())
+		data[ContextIdKey] = contextId
+		r = r.WithContext(context.WithValue(r.Context(), ContextDataMapKey, data))
+		r = r.WithContext(withAnnotations(r.Context()))
+		if l.config.HTTP.LevelHeader != "" {
+			if level, ok := readLevelHeader(r, &l.config.HTTP); ok {
+				r = r.WithContext(withRequestLevel(r.Context(), level))
+			}
+		}
+		if rate := l.config.HTTP.BodySampleRate; rate > 0 && rate < 1 {
+			r = r.WithContext(withBodySampling(r.Context(), rate))
+		}
+		start := time.Now()
+		r = r.WithContext(withRequestStart(r.Context(), start))
+		bodyCtx, buffers := withBodyBuffers(r.Context())
+		r = r.WithContext(bodyCtx)
+		ctx := r.Context()
+		if name != "" {
+			contextDataMap(ctx)[HandlerKey] = name
+		}
+		if id, ok := StreamIDFromContext(ctx); ok && r.ProtoMajor == 2 {
+			contextDataMap(ctx)[StreamIdKey] = strconv.FormatUint(uint64(id), 10)
+		}
+		if correlation := l.config.HTTP.CorrelationHash; correlation != nil {
+			if hash := correlation.hash(r); hash != "" {
+				contextDataMap(ctx)[CorrelationHashKey] = hash
+			}
+		}
+		rw := l.CreateResponseWrapper(w)
+
+		complete := l.logReceived(ctx, r, opts)
+
+		// every request gets exactly one completion, whether the handler
+		// returns early, writes an http.Error or panics
+		defer func() {
+			recovered := recover()
+			if recovered != nil {
+				rw.Panicked = true
+				l.logPanic(ctx, recovered, "recovered panic in handler")
+			} else if rw.Status == 0 {
+				// net/http answers 200 when the handler never wrote
+				rw.Status = http.StatusOK
+			}
+			complete(rw, time.Since(start))
+			buffers.release()
+
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+
+		if trailer != "" {
+			rw.Header().Set(trailer, contextId)
+		}
+	})
+}
+
+// AccessLogStyle selects the lines Middleware writes for each request.
+type AccessLogStyle int
+
+const (
+	// AccessLogBodies writes a "Request Body" line before the handler runs
+	// and a "Response Body" line after, as LogRequest and LogResponse do.
+	AccessLogBodies AccessLogStyle = iota
+
+	// AccessLogPair writes a "request received" line with the method and
+	// path before the handler runs, and a "request completed" line with
+	// the status and the duration after, so a request received but never
+	// completed stands out.
+	AccessLogPair
+
+	// AccessLogSummary writes one "request completed" line after the
+	// handler, with the fields of both lines of AccessLogPair.
+	AccessLogSummary
+)
+
+// logReceived writes what the access log style writes on entry and returns
+// the function writing the completion
+func (l *Log) logReceived(ctx context.Context, r *http.Request, opts RequestLogOptions) func(*LoggingResponseWriter, time.Duration) {
+	style := l.config.HTTP.AccessLogStyle
+	if style == AccessLogBodies {
+		l.LogRequestWithOptions(ctx, r, opts)
+		return func(rw *LoggingResponseWriter, _ time.Duration) {
+			l.LogResponse(ctx, rw)
+		}
+	}
+
+	var received *LogParams
+	if l.enabled(ctx, log.InfoLevel) {
+		received = l.requestParams(ctx, r, opts)
+		received.fields[MethodKey] = r.Method
+		if style == AccessLogPair {
+			l.emit(log.InfoLevel, received, "request received")
+			received = nil
+		}
+	}
+
+	return func(rw *LoggingResponseWriter, duration time.Duration) {
+		if !l.enabled(ctx, log.InfoLevel) {
+			return
+		}
+
+		lp := l.responseParams(ctx, rw)
+		if received != nil {
+			for key, value := range received.fields {
+				if _, ok := lp.fields[key]; !ok {
+					lp.fields[key] = value
+				}
+			}
+			for key := range received.keys {
+				lp.keys.add(key)
+			}
+		}
+		lp.fields[DurationKey] = duration.Milliseconds()
+		if start, ok := requestStart(ctx); ok && l.config.HTTP.LogClockJumps {
+			if jump := clockJump(start, duration); jump >= clockJumpThreshold || jump <= -clockJumpThreshold {
+				lp.fields[ClockJumpKey] = jump.Milliseconds()
+			}
+		}
+		l.emit(log.InfoLevel, lp, "request completed")
+	}
+}
+
+type namedHandler struct {
+	name string
+	http.Handler
+}
+
+// NamedHandler gives h a name reported in the "handler" field when
+// HTTPConfig.LogHandlerName is set, for routers whose routes all look like
+// the same function. It also works below a Middleware applied to the whole
+// router, in which case the name is only known from the response line on.
+func NamedHandler(name string, h http.Handler) http.Handler {
+	return &namedHandler{name: name, Handler: h}
+}
+
+func (h *namedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if data := contextDataMap(r.Context()); data != nil {
+		if _, ok := data[HandlerKey]; ok {
+			data[HandlerKey] = h.name
+		}
+	}
+
+	h.Handler.ServeHTTP(w, r)
+}
+
+// handlerName returns the name given by NamedHandler, the function name of
+// a HandlerFunc, or the type of any other handler
+func handlerName(h http.Handler) string {
+	switch handler := h.(type) {
+	case *namedHandler:
+		return handler.name
+	case http.HandlerFunc:
+		if fn := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()); fn != nil {
+			return fn.Name()
+		}
+	}
+
+	return reflect.TypeOf(h).String()
+}
+
+// readContextIdTrailer returns the context id sent in the trailer of r.
+// Trailers are only known once the body is read, so a request declaring
+// trailers has its body buffered here and replayed to the handler. At most
+// max bytes are buffered, maximumTrailerBody when max is not positive; a
+// longer body, or one failing to read, has no id read from its trailer and
+// is replayed from the buffered part on.
+func readContextIdTrailer(r *http.Request, trailer string, max int) string {
+	if _, declared := r.Trailer[http.CanonicalHeaderKey(trailer)]; !declared || r.Body == nil {
+		return ""
+	}
+	if max <= 0 {
+		max = maximumTrailerBody
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(r.Body, int64(max)+1))
+	if err != nil || len(buf) > max {
+		r.Body = &replayBody{Reader: io.MultiReader(bytes.NewReader(buf), r.Body), Closer: r.Body}
+		return ""
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(buf))
+
+	return r.Trailer.Get(trailer)
+}
+
+// readRequestIdHeader returns the id of the request id header of r, empty
+// when it is absent or not an id
+func readRequestIdHeader(r *http.Request, header string) string {
+	id := r.Header.Get(header)
+	if len(id) > maximumRequestIdLength {
+		return ""
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return ""
+		}
+	}
+
+	return id
+}
+
+// newContextId returns a random (version 4) UUID
+func newContextId() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,701 @@
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/c2fo/testify/assert"
+	"github.com/sirupsen/logrus"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMiddlewareLogsRequestAndResponse(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(`{}`)))
+
+	entries := hook.AllEntries()
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "Request Body", entries[0].Message)
+	assert.Equal(t, "Response Body", entries[1].Message)
+	assert.Equal(t, http.StatusCreated, entries[1].Data[ResponseCodeKey])
+	assert.NotEqual(t, "", entries[0].Data[ContextIdKey])
+	assert.Equal(t, entries[0].Data[ContextIdKey], entries[1].Data[ContextIdKey])
+}
+
+func TestMiddlewarePropagatesContextId(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+
+	request := httpLogger.AppendContextDataAndSetValue(httptest.NewRequest(http.MethodGet, "/", nil), "upstream-id")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	for _, entry := range hook.AllEntries() {
+		assert.Equal(t, "upstream-id", entry.Data[ContextIdKey])
+	}
+}
+
+func TestMiddlewareKeepsOuterFields(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{LogHandlerName: true}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpLogger.Info(r.Context(), "handling")
+		w.Write([]byte(`{}`))
+	}))
+
+	request := httpLogger.SetContextDataAndSetValue(httptest.NewRequest(http.MethodGet, "/", nil),
+		map[string]string{"language": "id"}, "outer-id")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	entries := hook.AllEntries()
+	assert.Equal(t, 3, len(entries))
+	for _, entry := range entries {
+		assert.Equal(t, "id", entry.Data["language"])
+		assert.Equal(t, "outer-id", entry.Data[ContextIdKey])
+		assert.NotNil(t, entry.Data[HandlerKey])
+	}
+
+	// the outer data map is not written to
+	_, tagged := contextDataMap(request.Context())[HandlerKey]
+	assert.False(t, tagged)
+}
+
+func TestMiddlewareRequestIdHeader(t *testing.T) {
+	const header = "X-Request-ID"
+
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{RequestIdHeader: header}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(header, "upstream-id")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, request)
+	assert.Equal(t, "upstream-id", rec.Header().Get(header))
+	assert.Equal(t, "upstream-id", hook.LastEntry().Data[ContextIdKey])
+
+	// absent or forged ids are replaced by a generated one
+	for _, id := range []string{"", "id\nlevel=error", strings.Repeat("x", 129)} {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set(header, id)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, request)
+
+		generated := rec.Header().Get(header)
+		assert.Equal(t, 36, len(generated))
+		assert.Equal(t, generated, hook.LastEntry().Data[ContextIdKey])
+	}
+}
+
+func TestMiddlewareMaxBodyBytes(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{MaxBodyBytes: 8}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+	var received []byte
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = ioutil.ReadAll(r.Body)
+		w.Write(received)
+	}))
+
+	body := "héllo, a longer body"
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	// the handler still reads the whole body
+	assert.Equal(t, body, string(received))
+
+	entries := hook.AllEntries()
+	assert.Equal(t, fmt.Sprintf("{%q}", "héllo, "), entries[0].Data[RequestKey])
+	assert.Equal(t, true, entries[0].Data[RequestTruncatedKey])
+	assert.Equal(t, int64(len(body)), entries[0].Data[RequestLengthKey])
+	assert.Equal(t, "héllo, ", entries[1].Data[ResponseKey])
+	assert.Equal(t, true, entries[1].Data[ResponseTruncatedKey])
+	assert.Equal(t, len(body), entries[1].Data[ResponseLengthKey])
+
+	// short bodies are logged whole
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("short")))
+	_, ok := hook.LastEntry().Data[ResponseTruncatedKey]
+	assert.False(t, ok)
+	assert.Equal(t, "short", hook.LastEntry().Data[ResponseKey])
+}
+
+func TestMiddlewareContextIdTrailer(t *testing.T) {
+	const trailer = "X-Context-Id"
+
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{ContextIdTrailer: trailer}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	request.Trailer = http.Header{trailer: []string{"upstream-id"}}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, request)
+
+	assert.Equal(t, "upstream-id", rec.Result().Trailer.Get(trailer))
+}
+
+func TestMiddlewareContextIdTrailerBoundsTheBody(t *testing.T) {
+	const trailer = "X-Context-Id"
+
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{ContextIdTrailer: trailer, MaxBodyBytes: 8}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	var body string
+	var readErr error
+	var contextId interface{}
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		body, readErr = string(b), err
+		contextId, _ = ContextValue(r.Context(), ContextIdKey)
+	}))
+
+	// a body within the bound has its trailer read
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("short"))
+	request.Trailer = http.Header{trailer: []string{"upstream-id"}}
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	assert.Equal(t, "short", body)
+	assert.Equal(t, "upstream-id", contextId)
+
+	// a longer one is replayed whole, without its trailer
+	request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a body longer than the bound"))
+	request.Trailer = http.Header{trailer: []string{"upstream-id"}}
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	assert.Equal(t, "a body longer than the bound", body)
+	assert.NotEqual(t, "upstream-id", contextId)
+
+	// and so is a failing one, its error included
+	failure := errors.New("connection reset")
+	request = httptest.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader("part"), iotest.ErrReader(failure)))
+	request.Trailer = http.Header{trailer: []string{"upstream-id"}}
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	assert.Equal(t, "part", body)
+	assert.Equal(t, failure, readErr)
+	assert.NotEqual(t, "upstream-id", contextId)
+}
+
+func TestMiddlewareHandlerName(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{LogHandlerName: true}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+	handler := httpLogger.Middleware(http.HandlerFunc(namedTestHandler))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, strings.HasSuffix(hook.LastEntry().Data[HandlerKey].(string), ".namedTestHandler"))
+
+	handler = httpLogger.Middleware(NamedHandler("get-items", http.HandlerFunc(namedTestHandler)))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "get-items", hook.LastEntry().Data[HandlerKey])
+}
+
+func namedTestHandler(w http.ResponseWriter, r *http.Request) {}
+
+func TestMiddlewareLogsPartialResponseOnPanic(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`partial`))
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			assert.Equal(t, "boom", recover())
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+
+	entries := hook.AllEntries()
+	assert.Equal(t, 3, len(entries))
+	assert.Equal(t, "boom", entries[1].Data[PanicKey])
+	assert.Equal(t, true, entries[2].Data[PanicKey])
+	assert.Equal(t, http.StatusAccepted, entries[2].Data[ResponseCodeKey])
+	assert.Equal(t, "partial", entries[2].Data[ResponseKey])
+}
+
+func TestLogRequestUserAgent(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{LogUserAgent: true}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("User-Agent", strings.Repeat("a", 1000))
+	httpLogger.LogRequest(sampleContext, request)
+
+	assert.Equal(t, strings.Repeat("a", maximumUserAgentLength)+truncatedSuffix, hook.LastEntry().Data[UserAgentKey])
+	_, ok := hook.LastEntry().Data[RefererKey]
+	assert.False(t, ok)
+}
+
+func TestMiddlewareBodySamplingIsPerRequest(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{BodySampleRate: 0.5}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+
+	withBody := 0
+	for i := 0; i < 200; i++ {
+		hook.Reset()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`)))
+
+		entries := hook.AllEntries()
+		assert.Equal(t, 2, len(entries))
+		_, requestBody := entries[0].Data[RequestKey]
+		_, responseBody := entries[1].Data[ResponseKey]
+		assert.Equal(t, requestBody, responseBody)
+		if requestBody {
+			withBody++
+		}
+	}
+
+	assert.True(t, withBody > 0 && withBody < 200)
+}
+
+func TestMiddlewareAnnotations(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Annotate(r.Context(), "user_tier", "gold")
+		for i := 0; i < 2*maximumAnnotations; i++ {
+			Annotate(r.Context(), fmt.Sprint("key_", i), i)
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	entries := hook.AllEntries()
+	_, ok := entries[0].Data["user_tier"]
+	assert.False(t, ok)
+	assert.Equal(t, "gold", entries[1].Data["user_tier"])
+	_, ok = entries[1].Data[fmt.Sprint("key_", maximumAnnotations)]
+	assert.False(t, ok)
+}
+
+// TestMiddlewareTagsConcurrently is meant to run with -race as well
+func TestMiddlewareTagsConcurrently(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				Tag(r.Context(), fmt.Sprint("worker_", i), "done")
+				httpLogger.Info(r.Context(), "working")
+			}(i)
+		}
+		wg.Wait()
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	last := hook.LastEntry()
+	for i := 0; i < 8; i++ {
+		assert.Equal(t, "done", last.Data[fmt.Sprint("worker_", i)])
+	}
+}
+
+// TestMiddlewarePooledBodies is meant to run with -race as well
+func TestMiddlewarePooledBodies(t *testing.T) {
+	// the body is replayed from the buffer alone, or from the buffer then
+	// the rest of the request
+	for _, max := range []int{0, 1 << 20} {
+		var out lockedBuffer
+		logger := logrus.New()
+		logger.SetOutput(&out)
+		httpLogger := newLog(logger, Config{Service: sampleString, AsyncBuffer: 1024, HTTP: HTTPConfig{MaxBodyBytes: max}})
+
+		// the sent body of each request, by context id
+		var sent sync.Map
+		handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contextId, _ := ContextValue(r.Context(), ContextIdKey)
+			sent.Store(contextId, r.Header.Get("X-Body"))
+			if r.Header.Get("X-Skip-Body") != "" {
+				// a body left unread stays in its buffer
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write(body)
+		}))
+
+		// bodies of different lengths, so a reused buffer would show a stale tail
+		serve := func(i int) {
+			body := strings.Repeat(fmt.Sprint(i, "-"), 1+i%7*100)
+			request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			request.Header.Set("X-Body", body)
+			if i%3 == 0 {
+				request.Header.Set("X-Skip-Body", "true")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, request)
+			if i%3 != 0 {
+				assert.Equal(t, body, rec.Body.String())
+			}
+		}
+		for i := 0; i < 50; i++ {
+			serve(i)
+		}
+		var wg sync.WaitGroup
+		for i := 50; i < 150; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				serve(i)
+			}(i)
+		}
+		wg.Wait()
+		assert.Nil(t, httpLogger.Shutdown(context.Background()))
+
+		requests := 0
+		for _, raw := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+			var line map[string]interface{}
+			assert.Nil(t, json.Unmarshal(raw, &line))
+
+			body, ok := sent.Load(line[ContextIdKey])
+			assert.True(t, ok)
+			switch line["msg"] {
+			case "Request Body":
+				requests++
+				assert.Equal(t, fmt.Sprintf("{%q}", body), line[RequestKey])
+			case "Response Body":
+				if response := line[ResponseKey]; response != "" {
+					assert.Equal(t, body, response)
+				}
+			}
+		}
+		assert.Equal(t, 150, requests)
+	}
+}
+
+func TestMiddlewareTags(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Tag(r.Context(), "tenant", "acme")
+		Tag(r.Context(), ContextIdKey, "spoofed")
+		for i := 0; i < 2*maximumTags; i++ {
+			Tag(r.Context(), fmt.Sprint("key_", i), "x")
+		}
+		httpLogger.Info(r.Context(), "handling")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	entries := hook.AllEntries()
+	assert.Equal(t, 3, len(entries))
+	_, ok := entries[0].Data["tenant"]
+	assert.False(t, ok)
+	for _, entry := range entries[1:] {
+		assert.Equal(t, "acme", entry.Data["tenant"])
+		assert.NotEqual(t, "spoofed", entry.Data[ContextIdKey])
+	}
+	_, ok = entries[2].Data[fmt.Sprint("key_", maximumTags)]
+	assert.False(t, ok)
+
+	// the next request starts without the tags
+	hook.Reset()
+	httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	_, ok = hook.LastEntry().Data["tenant"]
+	assert.False(t, ok)
+}
+
+func TestMiddlewareTraceSampled(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{LogTraceSampled: true}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	for _, entry := range hook.AllEntries() {
+		_, ok := entry.Data[SampledKey]
+		assert.False(t, ok)
+	}
+
+	hook.Reset()
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(trace.ContextWithSpanContext(r.Context(), spanContext))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	entries := hook.AllEntries()
+	assert.Equal(t, 2, len(entries))
+	for _, entry := range entries {
+		assert.Equal(t, true, entry.Data[SampledKey])
+	}
+}
+
+func TestMiddlewareResponseHeaders(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{
+		ResponseHeaders: []string{"content-type", "Cache-Control", "Set-Cookie", "ETag"},
+	}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Cache-Control", "no-store")
+		w.Header().Add("Cache-Control", "private")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("X-Internal", "hidden")
+		w.Write([]byte(`{}`))
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, map[string]string{
+		"Content-Type":  "application/json",
+		"Cache-Control": "no-store, private",
+		"Set-Cookie":    RedactedValue,
+	}, hook.LastEntry().Data[ResponseHeadersKey])
+}
+
+func TestMiddlewareBase64Bodies(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{Base64Bodies: true}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+	binary := []byte{0xff, 0x00, 'o', 'k'}
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(binary)))
+
+	entries := hook.AllEntries()
+	assert.Equal(t, "/wBvaw==", entries[0].Data[RequestB64Key])
+	assert.Equal(t, "/wBvaw==", entries[1].Data[ResponseB64Key])
+	assert.Equal(t, "base64", entries[1].Data[BodyEncodingKey])
+	_, ok := entries[1].Data[ResponseKey]
+	assert.False(t, ok)
+
+	hook.Reset()
+	handler = httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("text"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "text", hook.LastEntry().Data[ResponseKey])
+	_, ok = hook.LastEntry().Data[BodyEncodingKey]
+	assert.False(t, ok)
+}
+
+func TestMiddlewareLevelHeader(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{
+		LevelHeader: "X-Log-Level",
+		AuthorizeLevelHeader: func(r *http.Request) bool {
+			return r.Header.Get("X-Debug-Token") == "secret"
+		},
+	}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		httpLogger.Debug(r.Context(), "debugging")
+	}))
+	serve := func(token string) int {
+		hook.Reset()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Log-Level", "debug")
+		r.Header.Set("X-Debug-Token", token)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		return len(hook.AllEntries())
+	}
+
+	assert.Equal(t, 3, serve("secret"))
+	assert.Equal(t, 2, serve("guess"))
+
+	httpLogger.Debug(sampleContext, "outside a request")
+	assert.Equal(t, 2, len(hook.AllEntries()))
+}
+
+func TestMiddlewareAccessLogStyles(t *testing.T) {
+	for _, style := range []AccessLogStyle{AccessLogPair, AccessLogSummary} {
+		httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{AccessLogStyle: style}})
+		httpLogger.GetEntry().Logger.Out = ioutil.Discard
+		hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+		handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/orders", nil))
+
+		entries := hook.AllEntries()
+		completed := entries[len(entries)-1]
+		assert.Equal(t, "request completed", completed.Message)
+		assert.Equal(t, http.StatusAccepted, completed.Data[ResponseCodeKey])
+		_, ok := completed.Data[DurationKey].(int64)
+		assert.True(t, ok)
+
+		if style == AccessLogPair {
+			assert.Equal(t, 2, len(entries))
+			assert.Equal(t, "request received", entries[0].Message)
+			assert.Equal(t, http.MethodPut, entries[0].Data[MethodKey])
+			assert.Equal(t, entries[0].Data[ContextIdKey], completed.Data[ContextIdKey])
+		} else {
+			assert.Equal(t, 1, len(entries))
+			assert.Equal(t, http.MethodPut, completed.Data[MethodKey])
+			assert.Equal(t, "example.com/orders", completed.Data[PathKey])
+		}
+	}
+}
+
+func TestMiddlewareCacheHit(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	hit := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		MarkCacheHit(r.Context(), true)
+	}))
+	hit.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, true, hook.LastEntry().Data[CacheHitKey])
+
+	unknown := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unknown.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	_, ok := hook.LastEntry().Data[CacheHitKey]
+	assert.False(t, ok)
+}
+
+func TestMiddlewareCompletesEveryPath(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{AccessLogStyle: AccessLogSummary}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+	serve := func(handler http.HandlerFunc) *logrus.Entry {
+		hook.Reset()
+		func() {
+			defer func() { recover() }()
+			httpLogger.Middleware(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+
+		var completions []*logrus.Entry
+		for _, entry := range hook.AllEntries() {
+			if entry.Message == "request completed" {
+				completions = append(completions, entry)
+			}
+		}
+		assert.Equal(t, 1, len(completions))
+		_, ok := completions[0].Data[DurationKey]
+		assert.True(t, ok)
+		return completions[0]
+	}
+
+	earlyReturn := serve(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") == "" {
+			http.Error(w, "missing id", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte("found"))
+	})
+	assert.Equal(t, http.StatusBadRequest, earlyReturn.Data[ResponseCodeKey])
+
+	noWrite := serve(func(w http.ResponseWriter, r *http.Request) {})
+	assert.Equal(t, http.StatusOK, noWrite.Data[ResponseCodeKey])
+
+	panicked := serve(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	assert.Equal(t, true, panicked.Data[PanicKey])
+	assert.Equal(t, 0, panicked.Data[ResponseCodeKey])
+}
+
+func BenchmarkMiddlewareRequestBody(b *testing.B) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	body := strings.Repeat("x", 16<<10)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+}
+
+// hijackPushRecorder is a ResponseRecorder that can be hijacked and push
+type hijackPushRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	pushed   []string
+}
+
+func (r *hijackPushRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	return nil, nil, nil
+}
+
+func (r *hijackPushRecorder) Push(target string, opts *http.PushOptions) error {
+	r.pushed = append(r.pushed, target)
+	return nil
+}
+
+func TestMiddlewareResponseWriterInterfaces(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+		assert.Nil(t, w.(http.Pusher).Push("/app.js", nil))
+		_, _, err := w.(http.Hijacker).Hijack()
+		assert.Nil(t, err)
+	}))
+
+	rec := &hijackPushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+	assert.True(t, rec.Flushed)
+	assert.True(t, rec.hijacked)
+	assert.Equal(t, []string{"/app.js"}, rec.pushed)
+	assert.Equal(t, "data: 1\n\n", hook.LastEntry().Data[ResponseKey])
+
+	// writers without the interfaces report it
+	rw := httpLogger.CreateResponseWrapper(httptest.NewRecorder())
+	_, _, err := rw.Hijack()
+	assert.Equal(t, http.ErrNotSupported, err)
+	assert.Equal(t, http.ErrNotSupported, rw.Push("/app.js", nil))
+	assert.Nil(t, http.NewResponseController(rw).Flush())
+}
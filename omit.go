@@ -0,0 +1,55 @@
+package log
+
+import (
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OmitEmptyPolicy selects the empty fields left out of the written lines
+type OmitEmptyPolicy int
+
+const (
+	// KeepEmpty writes every field, empty or not.
+	KeepEmpty OmitEmptyPolicy = iota
+
+	// OmitEmptyStrings leaves out fields whose value is "" or nil.
+	OmitEmptyStrings
+
+	// OmitZeroValues leaves out fields whose value is nil, the zero value
+	// of its type, such as "", 0 or false, or an empty map or slice.
+	OmitZeroValues
+)
+
+// omitEmptyFields deletes from data the fields empty under policy
+func omitEmptyFields(data log.Fields, policy OmitEmptyPolicy) {
+	if policy == KeepEmpty {
+		return
+	}
+
+	for key, value := range data {
+		if isEmptyValue(value, policy) {
+			delete(data, key)
+		}
+	}
+}
+
+func isEmptyValue(value interface{}, policy OmitEmptyPolicy) bool {
+	if value == nil {
+		return true
+	}
+	if s, ok := value.(string); ok {
+		return s == ""
+	}
+	if policy != OmitZeroValues {
+		return false
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	}
+
+	return v.IsZero()
+}
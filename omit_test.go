@@ -0,0 +1,45 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestOmitEmptyFields(t *testing.T) {
+	fields := func() log.Fields {
+		return log.Fields{"user_id": "", "nil": nil, "count": 0, "ok": false, "tags": []string{}, "name": "fakhri"}
+	}
+
+	kept := fields()
+	omitEmptyFields(kept, KeepEmpty)
+	assert.Equal(t, fields(), kept)
+
+	strings := fields()
+	omitEmptyFields(strings, OmitEmptyStrings)
+	assert.Equal(t, log.Fields{"count": 0, "ok": false, "tags": []string{}, "name": "fakhri"}, strings)
+
+	zeros := fields()
+	omitEmptyFields(zeros, OmitZeroValues)
+	assert.Equal(t, log.Fields{"name": "fakhri"}, zeros)
+}
+
+func TestOmitEmptyConfig(t *testing.T) {
+	var entries []Entry
+	omitLogger := NewLoggerWithConfig(Config{
+		Service:   sampleString,
+		DryRun:    true,
+		OmitEmpty: OmitEmptyStrings,
+		OnEntry: func(entry Entry) {
+			entries = append(entries, entry)
+		},
+	})
+
+	omitLogger.InfoMap(sampleContext, map[string]interface{}{"user_id": "", "amount": 0})
+
+	assert.Equal(t, 1, len(entries))
+	_, ok := entries[0].Fields["user_id"]
+	assert.False(t, ok)
+	assert.Equal(t, 0, entries[0].Fields["amount"])
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"io"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Option sets up a logger built by NewLogger.
+type Option func(*options)
+
+// options collects the settings of the Option values given to NewLogger
+type options struct {
+	logger *log.Logger
+	config Config
+	fields log.Fields
+}
+
+// WithLevel writes the lines of level and above, InfoLevel by default.
+func WithLevel(level log.Level) Option {
+	return func(o *options) {
+		o.logger.SetLevel(level)
+	}
+}
+
+// WithOutput writes the lines to w instead of stderr.
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.logger.SetOutput(w)
+	}
+}
+
+// WithFormatter renders the lines with formatter instead of JSON, see
+// Config.Formatter.
+func WithFormatter(formatter log.Formatter) Option {
+	return func(o *options) {
+		o.config.Formatter = formatter
+	}
+}
+
+// WithStaticFields adds fields to every line, like service, e.g. the
+// region or the build of the process. Later calls add to the fields of
+// earlier ones. They are redacted and scrubbed like the fields of each line.
+func WithStaticFields(fields map[string]interface{}) Option {
+	return func(o *options) {
+		for key, value := range fields {
+			o.fields[key] = value
+		}
+	}
+}
+
+// WithCallerReporting adds the caller fields to the lines of every level,
+// see Config.ReportCaller.
+func WithCallerReporting(enabled bool) Option {
+	return func(o *options) {
+		o.config.ReportCaller = enabled
+	}
+}
+
+// WithRedaction masks the fields, JSON body entries, headers and query
+// parameters named by rules, see Config.RedactionRules. Later calls add to
+// the rules of earlier ones.
+func WithRedaction(rules ...RedactionRule) Option {
+	return func(o *options) {
+		o.config.RedactionRules = append(o.config.RedactionRules, rules...)
+	}
+}
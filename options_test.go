@@ -0,0 +1,60 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	"github.com/sirupsen/logrus"
+)
+
+func TestNewLoggerOptions(t *testing.T) {
+	var out bytes.Buffer
+	optionsLogger := NewLogger(sampleString,
+		WithLevel(logrus.DebugLevel),
+		WithOutput(&out),
+		WithStaticFields(map[string]interface{}{"region": "id-1"}),
+		WithCallerReporting(true))
+
+	optionsLogger.Debug(sampleContext, sampleString)
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "debug", line["level"])
+	assert.Equal(t, sampleString, line[ServiceKey])
+	assert.Equal(t, "id-1", line["region"])
+	assert.Equal(t, "11", line[ContextIdKey])
+	_, ok := line[FileKey]
+	assert.True(t, ok)
+}
+
+func TestStaticFieldsAreRedacted(t *testing.T) {
+	var out bytes.Buffer
+	redactedLogger := NewLogger(sampleString,
+		WithOutput(&out),
+		WithRedaction(RedactionRule{Key: "card", KeepLast: 4}),
+		WithStaticFields(map[string]interface{}{"card": "4111111111111111", "region": "id-1"}))
+
+	redactedLogger.Info(sampleContext, sampleString)
+	redactedLogger.Info(sampleContext, sampleString)
+
+	for _, raw := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		var line map[string]interface{}
+		assert.Nil(t, json.Unmarshal(raw, &line))
+		assert.Equal(t, "************1111", line["card"])
+		assert.Equal(t, "id-1", line["region"])
+	}
+	assert.False(t, strings.Contains(out.String(), "4111111111111111"))
+}
+
+func TestNewLoggerWithFormatter(t *testing.T) {
+	var out bytes.Buffer
+	textLogger := NewLogger(sampleString, WithOutput(&out), WithFormatter(&logrus.TextFormatter{DisableTimestamp: true}))
+
+	textLogger.Info(sampleContext, "text line")
+
+	assert.True(t, strings.HasPrefix(out.String(), `level=info msg="text line"`))
+	assert.Equal(t, "*logrus.TextFormatter", textLogger.Config().Formatter)
+}
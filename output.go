@@ -0,0 +1,65 @@
+package log
+
+import (
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Output is a destination with its own formatter, e.g. text on the console
+// and JSON in a file.
+type Output struct {
+	Writer io.Writer
+
+	// Formatter renders the lines of this output, JSON when nil
+	Formatter log.Formatter
+}
+
+// outputHook writes every entry to one Output. Hooks are fired under the
+// logger lock, so all outputs render the same entry one after the other.
+type outputHook struct {
+	writer    io.Writer
+	formatter log.Formatter
+}
+
+func (h *outputHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *outputHook) Fire(entry *log.Entry) error {
+	serialized, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.writer.Write(serialized)
+	return err
+}
+
+// nopFormatter skips rendering for the logger output when Outputs are used
+type nopFormatter struct{}
+
+func (nopFormatter) Format(*log.Entry) ([]byte, error) {
+	return nil, nil
+}
+
+// addOutputs adds one hook per output, the logger output itself is unused
+func addOutputs(logger *log.Logger, cfg *Config, writeErrors *writeErrors) {
+	for _, output := range cfg.Outputs {
+		formatter := output.Formatter
+		if formatter == nil {
+			formatter = &log.JSONFormatter{TimestampFormat: time.RFC3339Nano}
+		}
+
+		writer := output.Writer
+		if cfg.LengthPrefixed {
+			writer = &framedWriter{Writer: writer}
+		}
+
+		logger.AddHook(&outputHook{
+			writer:    &errorWriter{Writer: writer, errors: writeErrors},
+			formatter: &fieldFormatter{Formatter: formatter, config: cfg},
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package log
+
+import (
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PIIPattern is a kind of personal data scrubbed by SetPIIScrubbing
+type PIIPattern struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultPIIPatterns are the patterns a logger scrubs once SetPIIScrubbing
+// is on, in order, the most specific first. They favour catching PII over
+// precision, so expect false positives: "phone" also matches other digit
+// groups written like phone numbers, e.g. some order numbers, and "nik",
+// the 16 digit Indonesian national id, also matches card numbers and other
+// 16 digit ids.
+var DefaultPIIPatterns = []PIIPattern{
+	{Name: "email", Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)},
+	{Name: "iban", Pattern: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?:\s?[A-Z0-9]{4}){2,7}(?:\s?[A-Z0-9]{1,3})?\b`)},
+	{Name: "ssn", Pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)},
+	{Name: "nik", Pattern: regexp.MustCompile(`\b\d{16}\b`)},
+	{Name: "phone", Pattern: regexp.MustCompile(`(?:\+\d{1,3}[\s\-]?)?\(?\d{2,4}\)?[\s\-]\d{3,4}[\s\-]\d{3,4}\b|\+\d{8,15}\b`)},
+}
+
+// piiScrubbing holds the PII scrubbing shared by every logger derived from
+// the same root
+type piiScrubbing struct {
+	enabled atomic.Bool
+
+	mu       sync.RWMutex
+	patterns []PIIPattern
+}
+
+func newPIIScrubbing() *piiScrubbing {
+	return &piiScrubbing{patterns: append([]PIIPattern(nil), DefaultPIIPatterns...)}
+}
+
+// SetPIIScrubbing turns on or off, for this logger and the loggers derived
+// from it, the replacement by RedactedValue of the PII matched by the
+// patterns, DefaultPIIPatterns unless changed, in messages and in string
+// field values, nested maps included. It is off by default.
+func (l *Log) SetPIIScrubbing(enabled bool) {
+	l.pii.enabled.Store(enabled)
+}
+
+// AddPIIPattern adds a pattern scrubbed by SetPIIScrubbing, replacing the
+// pattern of the same name
+func (l *Log) AddPIIPattern(name string, pattern *regexp.Regexp) {
+	l.pii.mu.Lock()
+	defer l.pii.mu.Unlock()
+
+	l.pii.patterns = append(removePIIPattern(l.pii.patterns, name), PIIPattern{Name: name, Pattern: pattern})
+}
+
+// RemovePIIPattern stops scrubbing the pattern of that name, e.g. "phone"
+// where it matches too many ids
+func (l *Log) RemovePIIPattern(name string) {
+	l.pii.mu.Lock()
+	defer l.pii.mu.Unlock()
+
+	l.pii.patterns = removePIIPattern(l.pii.patterns, name)
+}
+
+// removePIIPattern returns a copy of patterns without name, so the slices
+// handed out by scrub are never modified
+func removePIIPattern(patterns []PIIPattern, name string) []PIIPattern {
+	kept := make([]PIIPattern, 0, len(patterns))
+	for _, p := range patterns {
+		if p.Name != name {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// scrub replaces the PII of message and of the string values of fields,
+// recording the keys of the scrubbed fields in keys
+func (p *piiScrubbing) scrub(fields log.Fields, message string, keys redactedKeys) string {
+	p.mu.RLock()
+	patterns := p.patterns
+	p.mu.RUnlock()
+
+	scrubPII(fields, patterns, keys, 0)
+
+	scrubbed, _ := scrubString(message, patterns)
+	return scrubbed
+}
+
+// correlationKeys are the injected ids lines are correlated by, never
+// scrubbed: the digit groups of a UUID can look like a phone number
+var correlationKeys = map[string]bool{
+	ContextIdKey:       true,
+	ParentContextIdKey: true,
+	TraceIdKey:         true,
+	SpanIdKey:          true,
+}
+
+// scrubPII scrubs the string values of m and of its nested maps, the
+// nested maps are copied so the caller values are left untouched. The
+// correlation ids of the line itself are left alone.
+func scrubPII(m map[string]interface{}, patterns []PIIPattern, keys redactedKeys, depth int) {
+	if depth > maximumRedactDepth {
+		return
+	}
+
+	for key, value := range m {
+		if depth == 0 && correlationKeys[key] {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			if scrubbed, ok := scrubString(v, patterns); ok {
+				m[key] = scrubbed
+				keys.add(key)
+			}
+		case map[string]interface{}:
+			copied := make(map[string]interface{}, len(v))
+			for k, nested := range v {
+				copied[k] = nested
+			}
+			scrubPII(copied, patterns, keys, depth+1)
+			m[key] = copied
+		}
+	}
+}
+
+// scrubString replaces the matches of patterns in s, reporting whether any
+func scrubString(s string, patterns []PIIPattern) (string, bool) {
+	scrubbed := false
+	for _, p := range patterns {
+		if p.Pattern.MatchString(s) {
+			s = p.Pattern.ReplaceAllLiteralString(s, RedactedValue)
+			scrubbed = true
+		}
+	}
+	return s, scrubbed
+}
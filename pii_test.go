@@ -0,0 +1,85 @@
+package log
+
+import (
+	"io/ioutil"
+	"regexp"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestDefaultPIIPatterns(t *testing.T) {
+	scrubbed := map[string]string{
+		"mail fakhri.m+log@example.co.id now": "mail " + RedactedValue + " now",
+		"call +62 812-3456-7890":              "call " + RedactedValue,
+		"call (021) 555-1234":                 "call " + RedactedValue,
+		"call +6281234567890":                 "call " + RedactedValue,
+		"iban DE89 3704 0044 0532 0130 00":    "iban " + RedactedValue,
+		"iban GB82WEST12345698765432":         "iban " + RedactedValue,
+		"ssn 123-45-6789":                     "ssn " + RedactedValue,
+		"nik 3174012345678901":                "nik " + RedactedValue,
+	}
+	for input, expected := range scrubbed {
+		actual, ok := scrubString(input, DefaultPIIPatterns)
+		assert.True(t, ok, input)
+		assert.Equal(t, expected, actual)
+	}
+
+	for _, kept := range []string{
+		"2024-01-02 10:11:12",
+		"0b6e4c3a-9f1d-4c2e-8a7b-1d2e3f4a5b6c",
+		"order 12345 took 250ms",
+	} {
+		actual, ok := scrubString(kept, DefaultPIIPatterns)
+		assert.False(t, ok, kept)
+		assert.Equal(t, kept, actual)
+	}
+}
+
+func TestPIIScrubbing(t *testing.T) {
+	piiLogger := NewLoggerWithConfig(Config{Service: sampleString, LogRedactedKeys: true})
+	piiLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(piiLogger.GetEntry().Logger)
+
+	payload := map[string]interface{}{"contact": "a@b.co"}
+	fields := map[string]interface{}{"email": "a@b.co", "payload": payload}
+
+	// off by default
+	piiLogger.InfoMap(sampleContext, fields, "signup a@b.co")
+	assert.Equal(t, "signup a@b.co", hook.LastEntry().Message)
+
+	piiLogger.SetPIIScrubbing(true)
+	piiLogger.InfoMap(sampleContext, fields, "signup a@b.co")
+	entry := hook.LastEntry()
+	assert.Equal(t, "signup "+RedactedValue, entry.Message)
+	assert.Equal(t, RedactedValue, entry.Data["email"])
+	assert.Equal(t, map[string]interface{}{"contact": RedactedValue}, entry.Data["payload"])
+	assert.Equal(t, []string{"contact", "email"}, entry.Data[RedactedKeysKey])
+	assert.Equal(t, "a@b.co", payload["contact"])
+
+	piiLogger.RemovePIIPattern("email")
+	piiLogger.AddPIIPattern("ticket", regexp.MustCompile(`TCK-\d+`))
+	piiLogger.Info(sampleContext, "a@b.co opened TCK-42")
+	assert.Equal(t, "a@b.co opened "+RedactedValue, hook.LastEntry().Message)
+}
+
+func TestPIIScrubbingKeepsCorrelationIds(t *testing.T) {
+	piiLogger, hook := NewLoggerWithTestHook(sampleString)
+	piiLogger.GetEntry().Logger.Out = ioutil.Discard
+	piiLogger.SetPIIScrubbing(true)
+
+	// a few percent of random UUIDs hold a group the phone pattern matches
+	for i := 0; i < 2000; i++ {
+		ctx := NewChildContext(piiLogger.BuildContextDataAndSetValue(newContextId()))
+		ctx = WithTrace(ctx, newContextId(), newContextId())
+		piiLogger.Info(ctx, "call +6281234567890")
+
+		entry := hook.LastEntry()
+		for _, key := range []string{ContextIdKey, ParentContextIdKey, TraceIdKey, SpanIdKey} {
+			value, _ := ContextValue(ctx, key)
+			assert.Equal(t, value, entry.Data[key])
+		}
+		assert.Equal(t, "call "+RedactedValue, entry.Message)
+	}
+}
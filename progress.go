@@ -0,0 +1,75 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// progressStep is the advance in percent that logs progress again
+	progressStep = 10
+
+	// progressInterval logs progress again after this long, however small
+	// the advance
+	progressInterval = 10 * time.Second
+)
+
+// progress holds the last logged progress of each operation, shared by
+// every logger derived from the same root
+type progress struct {
+	mu         sync.Mutex
+	operations map[string]progressState
+}
+
+type progressState struct {
+	pct    int
+	logged time.Time
+}
+
+// Progress logs the advance of a long operation, e.g. a batch job or a
+// migration, at Info with "operation", "current", "total" and
+// "progress_pct" fields. Calls are throttled per operation: a line is
+// written for the first call, then once the advance grew by 10 percent or
+// 10 seconds went by, and always on completion, when current reaches total.
+func (l *Log) Progress(ctx context.Context, operation string, current, total int) {
+	pct := 100
+	if total > 0 && current < total {
+		pct = current * 100 / total
+	}
+	if !l.progress.due(operation, pct, current >= total, time.Now()) {
+		return
+	}
+	if !l.enabled(ctx, log.InfoLevel) {
+		return
+	}
+
+	lp := l.newLogParams(ctx, log.InfoLevel)
+	lp.fields[OperationKey] = operation
+	lp.fields[CurrentKey] = current
+	lp.fields[TotalKey] = total
+	lp.fields[ProgressPctKey] = pct
+	l.emit(log.InfoLevel, lp, "progress")
+}
+
+// due reports whether the progress of operation should be logged and
+// records it, a completed operation is forgotten
+func (p *progress) due(operation string, pct int, completed bool, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if completed {
+		delete(p.operations, operation)
+		return true
+	}
+
+	last, ok := p.operations[operation]
+	if ok && pct-last.pct < progressStep && now.Sub(last.logged) < progressInterval {
+		return false
+	}
+
+	p.operations[operation] = progressState{pct: pct, logged: now}
+	return true
+}
@@ -0,0 +1,39 @@
+package log
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/c2fo/testify/assert"
+)
+
+func TestProgressIsThrottled(t *testing.T) {
+	progressLogger, hook := NewLoggerWithTestHook(sampleString)
+	progressLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	for i := 0; i <= 1000; i++ {
+		progressLogger.Progress(sampleContext, "migrate", i, 1000)
+	}
+
+	// the first call, one line per 10 percent and the completion
+	entries := hook.AllEntries()
+	assert.Equal(t, 11, len(entries))
+	last := entries[len(entries)-1]
+	assert.Equal(t, "migrate", last.Data[OperationKey])
+	assert.Equal(t, 100, last.Data[ProgressPctKey])
+	assert.Equal(t, 1000, last.Data[CurrentKey])
+	assert.Equal(t, 1000, last.Data[TotalKey])
+}
+
+func TestProgressIntervalAndOperations(t *testing.T) {
+	p := &progress{operations: make(map[string]progressState)}
+	now := time.Now()
+
+	assert.True(t, p.due("a", 1, false, now))
+	assert.False(t, p.due("a", 2, false, now.Add(time.Second)))
+	assert.True(t, p.due("b", 2, false, now.Add(time.Second)))
+	assert.True(t, p.due("a", 2, false, now.Add(progressInterval)))
+	assert.True(t, p.due("a", 2, true, now.Add(progressInterval)))
+	assert.Equal(t, 1, len(p.operations))
+}
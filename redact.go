@@ -0,0 +1,429 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// struct fields tagged `log:"redact"` are masked before they are logged
+	redactTagKey   = "log"
+	redactTagValue = "redact"
+
+	// maximumRedactDepth bounds the walk through nested values
+	maximumRedactDepth = 32
+)
+
+// RedactedValue replaces the value of every redacted field
+var RedactedValue = "[REDACTED]"
+
+// SetRedactionEnabled turns every redaction of this logger, and of the
+// loggers derived from it, on or off at runtime. It overrides the initial
+// state chosen from Config.RedactionEnvironments.
+func (l *Log) SetRedactionEnabled(enabled bool) {
+	l.redaction.Store(enabled)
+}
+
+// redactionEnabledFor reports whether redaction starts enabled for cfg, in
+// the environment of Config.Env, APP_ENV when unset
+func redactionEnabledFor(cfg *Config) bool {
+	if len(cfg.RedactionEnvironments) == 0 {
+		return true
+	}
+
+	env := cfg.Env
+	if env == "" {
+		env = os.Getenv(EnvVariable)
+	}
+	for _, redacted := range cfg.RedactionEnvironments {
+		if env == redacted {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+	// redactTypes caches whether a type contains a redact tag somewhere inside
+	redactTypes sync.Map
+)
+
+// RedactionRule masks the value of every field named Key, and of every
+// entry named Key in a nested map[string]interface{} value, e.g. a decoded
+// JSON payload.
+type RedactionRule struct {
+	Key string
+
+	// KeepLast keeps the last characters of the value and masks the others
+	// with "*", e.g. "****1234" for 4. Values not longer than KeepLast are
+	// masked whole. Zero replaces the value by RedactedValue.
+	KeepLast int
+}
+
+// redactedKeys collects the names of the redacted fields, struct field and
+// rule key names only, never values. A nil set collects nothing.
+type redactedKeys map[string]bool
+
+func (k redactedKeys) add(name string) {
+	if k != nil {
+		k[name] = true
+	}
+}
+
+// sorted lists the collected names in order
+func (k redactedKeys) sorted() []string {
+	names := make([]string, 0, len(k))
+	for name := range k {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// redactFields masks the tagged struct fields of every value in fields,
+// then applies rules to the keys, recording the redacted names in keys
+func redactFields(fields log.Fields, rules []RedactionRule, keys redactedKeys) {
+	for key, value := range fields {
+		fields[key] = redactStructTags(value, keys)
+	}
+	if len(rules) == 0 {
+		return
+	}
+
+	byKey := make(map[string]RedactionRule, len(rules))
+	for _, rule := range rules {
+		byKey[rule.Key] = rule
+	}
+	redactKeys(fields, byKey, keys, 0)
+}
+
+// redactKeys applies rules to the entries of m and of its nested maps, the
+// nested maps are copied so the caller values are left untouched
+func redactKeys(m map[string]interface{}, rules map[string]RedactionRule, keys redactedKeys, depth int) {
+	if depth > maximumRedactDepth {
+		return
+	}
+
+	for key, value := range m {
+		if rule, ok := rules[key]; ok {
+			m[key] = rule.mask(value)
+			keys.add(key)
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			copied := make(map[string]interface{}, len(nested))
+			for k, v := range nested {
+				copied[k] = v
+			}
+			redactKeys(copied, rules, keys, depth+1)
+			m[key] = copied
+		}
+	}
+}
+
+// httpRedactionRules returns the rules by key for the HTTP lines, nil when
+// redaction is off or there are no rules
+func (l *Log) httpRedactionRules() map[string]RedactionRule {
+	if len(l.config.RedactionRules) == 0 || !l.redaction.Load() {
+		return nil
+	}
+
+	byKey := make(map[string]RedactionRule, len(l.config.RedactionRules))
+	for _, rule := range l.config.RedactionRules {
+		byKey[rule.Key] = rule
+	}
+	return byKey
+}
+
+// redactBody applies rules to the entries of a JSON body, at any depth,
+// arrays included. Bodies that are not JSON or hold no such entry are
+// returned untouched, the others re-encoded.
+func redactBody(body string, rules map[string]RedactionRule, keys redactedKeys) string {
+	if len(rules) == 0 || !json.Valid([]byte(body)) {
+		return body
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || !redactJSON(value, rules, keys, 0) {
+		return body
+	}
+
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return string(redacted)
+}
+
+// redactCapturedBody applies the rules of lp to a logged body. A body cut
+// by HTTPConfig.MaxBodyBytes is no longer JSON the rules could mask, so it
+// is replaced whole by RedactedValue while there are rules.
+func (lp *LogParams) redactCapturedBody(body string, truncated bool) string {
+	if len(lp.rules) == 0 {
+		return body
+	}
+	if truncated {
+		return RedactedValue
+	}
+
+	return redactBody(body, lp.rules, lp.keys)
+}
+
+// redactJSON applies rules to a decoded JSON value in place and reports
+// whether anything was masked
+func redactJSON(value interface{}, rules map[string]RedactionRule, keys redactedKeys, depth int) bool {
+	if depth > maximumRedactDepth {
+		return false
+	}
+
+	redacted := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if rule, ok := rules[key]; ok {
+				v[key] = rule.mask(nested)
+				keys.add(key)
+				redacted = true
+				continue
+			}
+			redacted = redactJSON(nested, rules, keys, depth+1) || redacted
+		}
+	case []interface{}:
+		for _, nested := range v {
+			redacted = redactJSON(nested, rules, keys, depth+1) || redacted
+		}
+	}
+	return redacted
+}
+
+// redactHeaders applies rules to headers, header names matching rule keys
+// whatever their case
+func redactHeaders(headers map[string]string, rules map[string]RedactionRule, keys redactedKeys) {
+	for _, rule := range rules {
+		name := http.CanonicalHeaderKey(rule.Key)
+		if value, ok := headers[name]; ok {
+			headers[name] = fmt.Sprint(rule.mask(value))
+			keys.add(rule.Key)
+		}
+	}
+}
+
+// redactQuery applies rules to the parameters of a raw query, the other
+// parameters and their order are kept as sent
+func redactQuery(rawQuery string, rules map[string]RedactionRule, keys redactedKeys) string {
+	if len(rules) == 0 {
+		return rawQuery
+	}
+
+	params := strings.Split(rawQuery, "&")
+	for i, param := range params {
+		name, value, _ := strings.Cut(param, "=")
+		key, err := url.QueryUnescape(name)
+		if err != nil {
+			continue
+		}
+		rule, ok := rules[key]
+		if !ok {
+			continue
+		}
+
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+		params[i] = name + "=" + fmt.Sprint(rule.mask(value))
+		keys.add(key)
+	}
+	return strings.Join(params, "&")
+}
+
+// mask hides value as the rule asks
+func (r RedactionRule) mask(value interface{}) interface{} {
+	if r.KeepLast <= 0 || value == nil {
+		return RedactedValue
+	}
+
+	runes := []rune(stringifyValue(value))
+	if len(runes) <= r.KeepLast {
+		return strings.Repeat("*", len(runes))
+	}
+
+	masked := len(runes) - r.KeepLast
+	return strings.Repeat("*", masked) + string(runes[masked:])
+}
+
+// redactStructTags returns value with every `log:"redact"` field masked.
+// Values whose type holds no such tag are returned untouched, the others are
+// converted to the maps and slices encoding/json would produce for them.
+func redactStructTags(value interface{}, keys redactedKeys) interface{} {
+	if value == nil || !hasRedactTag(reflect.TypeOf(value)) {
+		return value
+	}
+
+	return redactValue(reflect.ValueOf(value), keys, 0)
+}
+
+func redactValue(v reflect.Value, keys redactedKeys, depth int) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if depth > maximumRedactDepth || !hasRedactTag(v.Type()) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem(), keys, depth+1)
+	case reflect.Struct:
+		result := make(map[string]interface{}, v.NumField())
+		redactStruct(v, result, keys, depth)
+		return result
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		result := make([]interface{}, v.Len())
+		for i := range result {
+			result[i] = redactValue(v.Index(i), keys, depth+1)
+		}
+		return result
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		result := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			result[fmt.Sprint(iter.Key().Interface())] = redactValue(iter.Value(), keys, depth+1)
+		}
+		return result
+	}
+
+	return v.Interface()
+}
+
+func redactStruct(v reflect.Value, result map[string]interface{}, keys redactedKeys, depth int) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name, omitEmpty, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+
+		value := v.Field(i)
+		if field.Tag.Get(redactTagKey) == redactTagValue {
+			result[name] = RedactedValue
+			keys.add(name)
+			continue
+		}
+
+		// embedded structs are flattened the same way encoding/json does
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			for value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					break
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				redactStruct(value, result, keys, depth+1)
+				continue
+			}
+			if field.PkgPath != "" {
+				continue
+			}
+		}
+
+		if omitEmpty && value.IsZero() {
+			continue
+		}
+		result[name] = redactValue(value, keys, depth+1)
+	}
+}
+
+// jsonFieldName resolves the key encoding/json would use for field
+func jsonFieldName(field reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	return name, omitEmpty, false
+}
+
+// hasRedactTag reports whether t or any type reachable from it has a field
+// tagged for redaction
+func hasRedactTag(t reflect.Type) bool {
+	if cached, ok := redactTypes.Load(t); ok {
+		return cached.(bool)
+	}
+
+	result := scanRedactTag(t, map[reflect.Type]bool{})
+	redactTypes.Store(t, result)
+	return result
+}
+
+func scanRedactTag(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	// types with their own encoding keep it
+	if t.Implements(jsonMarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return scanRedactTag(t.Elem(), seen)
+	case reflect.Map:
+		return scanRedactTag(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.Tag.Get(redactTagKey) == redactTagValue {
+				return true
+			}
+			if scanRedactTag(field.Type, seen) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
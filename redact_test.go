@@ -0,0 +1,155 @@
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+type redactAddress struct {
+	Street string `json:"street" log:"redact"`
+	City   string `json:"city"`
+}
+
+type redactUser struct {
+	Name      string                    `json:"name"`
+	Password  string                    `json:"password" log:"redact"`
+	Addresses []redactAddress           `json:"addresses"`
+	Manager   *redactUser               `json:"manager,omitempty"`
+	Extra     map[string]*redactAddress `json:"extra"`
+}
+
+func TestRedactStructTags(t *testing.T) {
+	user := &redactUser{
+		Name:      "fakhri",
+		Password:  "secret",
+		Addresses: []redactAddress{{Street: "Jl. Sudirman", City: "Jakarta"}},
+		Manager:   &redactUser{Name: "boss", Password: "secret"},
+		Extra:     map[string]*redactAddress{"home": {Street: "Jl. Thamrin", City: "Jakarta"}},
+	}
+
+	redacted := redactStructTags(user, nil).(map[string]interface{})
+
+	assert.Equal(t, "fakhri", redacted["name"])
+	assert.Equal(t, RedactedValue, redacted["password"])
+	address := redacted["addresses"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, RedactedValue, address["street"])
+	assert.Equal(t, "Jakarta", address["city"])
+	assert.Equal(t, RedactedValue, redacted["manager"].(map[string]interface{})["password"])
+	assert.Equal(t, RedactedValue, redacted["extra"].(map[string]interface{})["home"].(map[string]interface{})["street"])
+
+	// values without redact tags keep their type
+	assert.Equal(t, sampleObjects, redactStructTags(sampleObjects, nil))
+}
+
+func TestRedactionEnvironments(t *testing.T) {
+	t.Setenv(EnvVariable, "staging")
+
+	stagingLogger, hook := NewLoggerWithTestHook(sampleString)
+	stagingLogger.GetEntry().Logger.Out = ioutil.Discard
+	user := redactUser{Name: "fakhri", Password: "secret"}
+
+	// NewLoggerWithTestHook has no environments, redaction stays on
+	stagingLogger.InfoMap(sampleContext, map[string]interface{}{"user": user})
+	assert.Equal(t, RedactedValue, hook.LastEntry().Data["user"].(map[string]interface{})["password"])
+
+	stagingLogger.SetRedactionEnabled(false)
+	stagingLogger.InfoMap(sampleContext, map[string]interface{}{"user": user})
+	assert.Equal(t, user, hook.LastEntry().Data["user"])
+
+	productionOnly := Config{RedactionEnvironments: []string{"production"}}
+	assert.False(t, redactionEnabledFor(&productionOnly))
+	t.Setenv(EnvVariable, "production")
+	assert.True(t, redactionEnabledFor(&productionOnly))
+}
+
+func TestRedactionEnvironmentsFollowConfigEnv(t *testing.T) {
+	t.Setenv(EnvVariable, "")
+
+	envLogger := NewLoggerWithConfig(Config{Service: sampleString, Env: "production", RedactionEnvironments: []string{"production"}})
+	envLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(envLogger.GetEntry().Logger)
+
+	envLogger.InfoMap(sampleContext, map[string]interface{}{"user": redactUser{Name: "fakhri", Password: "secret"}})
+	assert.Equal(t, RedactedValue, hook.LastEntry().Data["user"].(map[string]interface{})["password"])
+
+	// Config.Env wins over APP_ENV
+	t.Setenv(EnvVariable, "production")
+	assert.False(t, redactionEnabledFor(&Config{Env: "staging", RedactionEnvironments: []string{"production"}}))
+}
+
+func TestRedactionRules(t *testing.T) {
+	ruleLogger := NewLoggerWithConfig(Config{Service: sampleString, RedactionRules: []RedactionRule{
+		{Key: "card", KeepLast: 4},
+		{Key: "pin", KeepLast: 4},
+		{Key: "token"},
+	}})
+	ruleLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(ruleLogger.GetEntry().Logger)
+
+	payload := map[string]interface{}{"token": "abc", "amount": 10}
+	ruleLogger.InfoMap(sampleContext, map[string]interface{}{
+		"card":    "4111111111111234",
+		"pin":     "12",
+		"payload": payload,
+	})
+
+	data := hook.LastEntry().Data
+	assert.Equal(t, "************1234", data["card"])
+	assert.Equal(t, "**", data["pin"])
+	assert.Equal(t, map[string]interface{}{"token": RedactedValue, "amount": 10}, data["payload"])
+	assert.Equal(t, "abc", payload["token"])
+}
+
+func TestLogRedactedKeys(t *testing.T) {
+	keysLogger := NewLoggerWithConfig(Config{
+		Service:         sampleString,
+		LogRedactedKeys: true,
+		RedactionRules:  []RedactionRule{{Key: "card", KeepLast: 4}},
+	})
+	keysLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(keysLogger.GetEntry().Logger)
+
+	keysLogger.InfoMap(sampleContext, map[string]interface{}{
+		"user": redactUser{Name: "fakhri", Password: "secret", Addresses: []redactAddress{{Street: "Jl. Sudirman"}}},
+		"card": "4111111111111234",
+	})
+	assert.Equal(t, []string{"card", "password", "street"}, hook.LastEntry().Data[RedactedKeysKey])
+
+	// nothing redacted, no field
+	keysLogger.InfoMap(sampleContext, map[string]interface{}{"amount": 10})
+	_, ok := hook.LastEntry().Data[RedactedKeysKey]
+	assert.False(t, ok)
+}
+
+func TestWithRedactionHTTP(t *testing.T) {
+	redactionLogger := NewLogger(sampleString,
+		WithOutput(ioutil.Discard),
+		WithRedaction(RedactionRule{Key: "password"}, RedactionRule{Key: "card_number", KeepLast: 4}),
+		WithRedaction(RedactionRule{Key: "authorization"}, RedactionRule{Key: "token"}))
+	hook := logrusTest.NewLocal(redactionLogger.GetEntry().Logger)
+
+	r := httptest.NewRequest(http.MethodPost, "/login?user=ana&token=a%2Bb&page=2",
+		strings.NewReader(`{"user":"ana","password":"secret","cards":[{"card_number":"4111111111111234"}]}`))
+	r.Header.Set("Authorization", "Bearer abc")
+	redactionLogger.LogRequestWithOptions(sampleContext, r, RequestLogOptions{Query: true, Body: true, Headers: []string{"Authorization"}})
+
+	data := hook.LastEntry().Data
+	assert.Equal(t, "user=ana&token=[REDACTED]&page=2", data[QueryKey])
+	assert.Equal(t, map[string]string{"Authorization": RedactedValue}, data[RequestHeadersKey])
+	assert.Equal(t, fmt.Sprintf("{%q}", `{"cards":[{"card_number":"************1234"}],"password":"[REDACTED]","user":"ana"}`), data[RequestKey])
+
+	// bodies without redacted entries are kept as sent
+	redactionLogger.LogResponse(sampleContext, &LoggingResponseWriter{Status: http.StatusOK, Body: `{"b":1, "a":2}`})
+	assert.Equal(t, `{"b":1, "a":2}`, hook.LastEntry().Data[ResponseKey])
+
+	redactionLogger.SetRedactionEnabled(false)
+	redactionLogger.LogResponse(sampleContext, &LoggingResponseWriter{Status: http.StatusOK, Body: `{"token":"abc"}`})
+	assert.Equal(t, `{"token":"abc"}`, hook.LastEntry().Data[ResponseKey])
+}
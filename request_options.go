@@ -0,0 +1,98 @@
+package log
+
+import (
+	"context"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestLogOptions selects the request attributes written on the request
+// line, each one a field of its own.
+type RequestLogOptions struct {
+	// Method adds "http_method"
+	Method bool
+
+	// Path adds "url_path", the host and path of the URL
+	Path bool
+
+	// Query adds "url_query", the raw query string
+	Query bool
+
+	// Headers adds "request_headers" with the values of these headers
+	// only, so no header is logged unless listed here
+	Headers []string
+
+	// Body adds "request", subject to HTTPConfig.BodySampleRate
+	Body bool
+
+	// RemoteAddr adds "remote_addr", the network address of the client
+	RemoteAddr bool
+
+	// UserAgent adds "user_agent" and "referer"
+	UserAgent bool
+}
+
+// DefaultRequestLogOptions log the path and the body, as LogRequest always
+// did.
+var DefaultRequestLogOptions = RequestLogOptions{Path: true, Body: true}
+
+// LogRequestWithOptions writes the request line of r with the attributes
+// selected by opts, e.g. a lighter set for a health check route.
+func (l *Log) LogRequestWithOptions(ctx context.Context, r *http.Request, opts RequestLogOptions) {
+	if !l.enabled(ctx, log.InfoLevel) {
+		return
+	}
+
+	l.emit(log.InfoLevel, l.requestParams(ctx, r, opts), "Request Body")
+}
+
+// MiddlewareWithOptions is Middleware writing the request attributes
+// selected by opts, to give a route its own access log shape.
+func (l *Log) MiddlewareWithOptions(opts RequestLogOptions, next http.Handler) http.Handler {
+	return l.middleware(next, opts)
+}
+
+// requestLogOptions are the options of LogRequest and Middleware
+func (l *Log) requestLogOptions() RequestLogOptions {
+	if opts := l.config.HTTP.RequestLogOptions; opts != nil {
+		return *opts
+	}
+
+	opts := DefaultRequestLogOptions
+	opts.UserAgent = l.config.HTTP.LogUserAgent
+	return opts
+}
+
+func (lp *LogParams) injectRequestOptions(ctx context.Context, r *http.Request, opts RequestLogOptions) *LogParams {
+	if opts.Method {
+		lp.fields[MethodKey] = r.Method
+	}
+	if opts.Path {
+		lp.injectURLPath(ctx, r)
+	}
+	if opts.Query && r.URL.RawQuery != "" {
+		lp.fields[QueryKey] = redactQuery(r.URL.RawQuery, lp.rules, lp.keys)
+	}
+	if len(opts.Headers) > 0 {
+		headers := make(map[string]string, len(opts.Headers))
+		for _, name := range opts.Headers {
+			if value := r.Header.Get(name); value != "" {
+				headers[http.CanonicalHeaderKey(name)] = value
+			}
+		}
+		redactHeaders(headers, lp.rules, lp.keys)
+		lp.fields[RequestHeadersKey] = headers
+	}
+	if opts.Body && bodySampled(ctx) {
+		lp.injectRequestBody(ctx, r)
+	}
+	if opts.RemoteAddr {
+		lp.fields[RemoteAddrKey] = r.RemoteAddr
+	}
+	if opts.UserAgent {
+		lp.injectUserAgent(r)
+	}
+
+	return lp
+}
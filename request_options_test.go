@@ -0,0 +1,57 @@
+package log
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+)
+
+func TestLogRequestWithOptions(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	request := httptest.NewRequest(http.MethodPost, "/orders?page=2", strings.NewReader(`{}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bearer secret")
+
+	httpLogger.LogRequestWithOptions(sampleContext, request, RequestLogOptions{
+		Method:     true,
+		Query:      true,
+		Headers:    []string{"content-type"},
+		RemoteAddr: true,
+	})
+
+	data := hook.LastEntry().Data
+	assert.Equal(t, http.MethodPost, data[MethodKey])
+	assert.Equal(t, "page=2", data[QueryKey])
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, data[RequestHeadersKey])
+	assert.Equal(t, request.RemoteAddr, data[RemoteAddrKey])
+	for _, key := range []string{PathKey, RequestKey, UserAgentKey} {
+		_, ok := data[key]
+		assert.False(t, ok)
+	}
+}
+
+func TestMiddlewareWithOptionsPerRoute(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	noop := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	httpLogger.Middleware(noop).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+	defaults := hook.AllEntries()[0].Data
+	assert.Equal(t, "example.com/items", defaults[PathKey])
+	_, ok := defaults[RequestKey]
+	assert.True(t, ok)
+
+	hook.Reset()
+	health := httpLogger.MiddlewareWithOptions(RequestLogOptions{Method: true}, noop)
+	health.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	light := hook.AllEntries()[0].Data
+	assert.Equal(t, http.MethodGet, light[MethodKey])
+	_, ok = light[PathKey]
+	assert.False(t, ok)
+}
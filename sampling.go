@@ -0,0 +1,105 @@
+package log
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"math/rand"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// safe typing https://golang.org/pkg/context/#WithValue
+type logSampleRateKeyType string
+
+const (
+	logSampleRateKey logSampleRateKeyType = "log_sample_rate"
+
+	// bodySampledKey holds the body sampling decision of a request
+	bodySampledKey logSampleRateKeyType = "body_sampled"
+)
+
+// SetLogSampleRate returns a copy of ctx in which Info, Debug and Trace lines
+// are only written with the given probability, e.g. 0.1 keeps one line in ten.
+// Warn and more severe lines are always written, see Config.UnsafeSampleErrors.
+// A rate of 1 or more keeps
+// every line, a rate of 0 or less drops all sampled levels.
+func SetLogSampleRate(ctx context.Context, rate float64) context.Context {
+	return context.WithValue(ctx, logSampleRateKey, rate)
+}
+
+// sampleable reports whether sampling may drop a line at level. Error, Fatal
+// and Panic lines are never dropped, losing one to sampling makes incidents
+// impossible to debug; only Config.UnsafeSampleErrors opens Warn and Error
+// lines to sampling, Fatal and Panic are kept whatever the configuration.
+func sampleable(level log.Level, unsafeSampleErrors bool) bool {
+	if level >= log.InfoLevel {
+		return true
+	}
+
+	return unsafeSampleErrors && level >= log.ErrorLevel
+}
+
+// isSampled decides whether a line at level survives the sample rate of ctx
+func isSampled(ctx context.Context, level log.Level, unsafeSampleErrors bool) bool {
+	if !sampleable(level, unsafeSampleErrors) {
+		return true
+	}
+
+	rate, ok := ctx.Value(logSampleRateKey).(float64)
+	if !ok {
+		return true
+	}
+
+	return sample(rate)
+}
+
+// sample keeps an event with probability rate
+func sample(rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+
+	return rand.Float64() < rate
+}
+
+// withBodySampling decides once for the whole request whether its bodies
+// are logged, so a request logs both bodies or neither
+func withBodySampling(ctx context.Context, rate float64) context.Context {
+	return context.WithValue(ctx, bodySampledKey, sample(rate))
+}
+
+// bodySampled reports whether the bodies of the request of ctx are logged,
+// true when no decision was made
+func bodySampled(ctx context.Context) bool {
+	sampled, ok := ctx.Value(bodySampledKey).(bool)
+	return !ok || sampled
+}
+
+// contextSampled decides whether the lines of the context id of ctx are kept
+// at rate. The decision hashes the id, so it is the same for every line of a
+// request, on every instance; lines without a context id are kept.
+func contextSampled(ctx context.Context, rate float64) bool {
+	value, ok := ContextValue(ctx, ContextIdKey)
+	if !ok {
+		return true
+	}
+	contextId, _ := value.(string)
+
+	h := fnv.New64a()
+	h.Write([]byte(contextId))
+
+	// ids often differ in their last characters only, the murmur3
+	// finalizer spreads that difference over the high bits compared
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+
+	return float64(x) < rate*math.MaxUint64
+}
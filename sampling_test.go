@@ -0,0 +1,64 @@
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestSamplingNeverDropsErrors(t *testing.T) {
+	sampledLogger, recorder := NewLoggerWithRecorder(sampleString)
+	sampledLogger.GetEntry().Logger.Out = ioutil.Discard
+	sampledLogger.SetLevel(log.DebugLevel)
+
+	ctx := SetLogSampleRate(sampleContext, 0)
+	for i := 0; i < 1000; i++ {
+		sampledLogger.Debug(ctx, sampleString)
+		sampledLogger.Info(ctx, sampleString)
+		sampledLogger.Warn(ctx, sampleString)
+		sampledLogger.Error(ctx, sampleString)
+	}
+
+	assert.Equal(t, 0, recorder.Count(log.DebugLevel))
+	assert.Equal(t, 0, recorder.Count(log.InfoLevel))
+	assert.Equal(t, 1000, recorder.Count(log.WarnLevel))
+	assert.Equal(t, 1000, recorder.Count(log.ErrorLevel))
+}
+
+func TestSampleableLevels(t *testing.T) {
+	assert.False(t, sampleable(log.ErrorLevel, false))
+	assert.True(t, sampleable(log.ErrorLevel, true))
+	assert.False(t, sampleable(log.FatalLevel, true))
+	assert.False(t, sampleable(log.PanicLevel, true))
+	assert.True(t, sampleable(log.InfoLevel, false))
+}
+
+func TestContextSampleRateIsPerContext(t *testing.T) {
+	sampledLogger := NewLoggerWithConfig(Config{Service: sampleString, ContextSampleRate: 0.5})
+	sampledLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(sampledLogger.GetEntry().Logger)
+
+	kept := 0
+	for i := 0; i < 200; i++ {
+		ctx := sampledLogger.BuildContextDataAndSetValue(fmt.Sprint("request-", i))
+
+		hook.Reset()
+		for j := 0; j < 5; j++ {
+			sampledLogger.Info(ctx, sampleString)
+		}
+		sampledLogger.Error(ctx, sampleString)
+
+		infos := len(hook.AllEntries()) - 1
+		assert.True(t, infos == 0 || infos == 5)
+		assert.Equal(t, log.ErrorLevel, hook.LastEntry().Level)
+		if infos == 5 {
+			kept++
+		}
+	}
+
+	assert.True(t, kept > 50 && kept < 150)
+}
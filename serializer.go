@@ -0,0 +1,95 @@
+package log
+
+import (
+	"reflect"
+	"sync"
+)
+
+var (
+	serializersMu sync.RWMutex
+	serializers   = map[reflect.Type]func(interface{}) interface{}{}
+
+	// interfaceSerializers apply to the values implementing their
+	// interface, in registration order
+	interfaceSerializers []interfaceSerializer
+)
+
+type interfaceSerializer struct {
+	iface     reflect.Type
+	serialize func(interface{}) interface{}
+}
+
+// RegisterFieldSerializer sets how field values of type t are logged, e.g.
+// sql.NullString as its string or nil. The serializer also applies to
+// pointers to t; nil pointers are logged as nil without calling it.
+// Registering nil removes the serializer of t.
+func RegisterFieldSerializer(t reflect.Type, serializer func(interface{}) interface{}) {
+	serializersMu.Lock()
+	defer serializersMu.Unlock()
+
+	if serializer == nil {
+		delete(serializers, t)
+		return
+	}
+	serializers[t] = serializer
+}
+
+// RegisterInterfaceSerializer sets how field values implementing the
+// interface type iface are logged, e.g. every proto.Message, when no
+// serializer is registered for their own type. Registering nil removes the
+// serializer of iface.
+func RegisterInterfaceSerializer(iface reflect.Type, serializer func(interface{}) interface{}) {
+	serializersMu.Lock()
+	defer serializersMu.Unlock()
+
+	registered := interfaceSerializers[:0:0]
+	for _, s := range interfaceSerializers {
+		if s.iface != iface {
+			registered = append(registered, s)
+		}
+	}
+	if serializer != nil {
+		registered = append(registered, interfaceSerializer{iface: iface, serialize: serializer})
+	}
+	interfaceSerializers = registered
+}
+
+// serializeValue applies the serializer registered for the type of value
+func serializeValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	serializersMu.RLock()
+	defer serializersMu.RUnlock()
+
+	if len(serializers) == 0 && len(interfaceSerializers) == 0 {
+		return value
+	}
+
+	t := reflect.TypeOf(value)
+	if serializer, ok := serializers[t]; ok {
+		if t.Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+			return nil
+		}
+		return serializer(value)
+	}
+
+	if t.Kind() == reflect.Ptr {
+		if serializer, ok := serializers[t.Elem()]; ok {
+			v := reflect.ValueOf(value)
+			if v.IsNil() {
+				return nil
+			}
+			return serializer(v.Elem().Interface())
+		}
+	}
+
+	for _, s := range interfaceSerializers {
+		if t.Implements(s.iface) {
+			return s.serialize(value)
+		}
+	}
+
+	return value
+}
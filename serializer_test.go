@@ -0,0 +1,62 @@
+package log
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+)
+
+func TestRegisterFieldSerializer(t *testing.T) {
+	nullStringType := reflect.TypeOf(sql.NullString{})
+	RegisterFieldSerializer(nullStringType, func(value interface{}) interface{} {
+		if s := value.(sql.NullString); s.Valid {
+			return s.String
+		}
+		return nil
+	})
+	defer RegisterFieldSerializer(nullStringType, nil)
+
+	var buf bytes.Buffer
+	serializedLogger := NewLogger(sampleString)
+	serializedLogger.GetEntry().Logger.Out = &buf
+
+	var nilPointer *sql.NullString
+	serializedLogger.InfoMap(sampleContext, map[string]interface{}{
+		"name":    sql.NullString{String: "fakhri", Valid: true},
+		"pointer": &sql.NullString{String: "pointed", Valid: true},
+		"nil":     nilPointer,
+		"invalid": sql.NullString{},
+	}, sampleString)
+
+	var line map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, "fakhri", line["name"])
+	assert.Equal(t, "pointed", line["pointer"])
+	assert.Nil(t, line["nil"])
+	assert.Nil(t, line["invalid"])
+}
+
+type celsius float64
+
+func (c celsius) String() string { return "celsius" }
+
+func TestRegisterInterfaceSerializer(t *testing.T) {
+	stringerType := reflect.TypeOf((*interface{ String() string })(nil)).Elem()
+	RegisterInterfaceSerializer(stringerType, func(value interface{}) interface{} {
+		return "stringer"
+	})
+	defer RegisterInterfaceSerializer(stringerType, nil)
+
+	assert.Equal(t, "stringer", serializeValue(celsius(21)))
+	assert.Equal(t, 21, serializeValue(21))
+
+	RegisterFieldSerializer(reflect.TypeOf(celsius(0)), func(value interface{}) interface{} {
+		return "own type"
+	})
+	defer RegisterFieldSerializer(reflect.TypeOf(celsius(0)), nil)
+	assert.Equal(t, "own type", serializeValue(celsius(21)))
+}
@@ -0,0 +1,55 @@
+package log
+
+import "os"
+
+const (
+	// ServiceVersionEnvVariable holds the default version of SetServiceInfo
+	ServiceVersionEnvVariable = "SERVICE_VERSION"
+
+	// InstanceEnvVariable holds the default instance of SetServiceInfo, e.g.
+	// the pod name; the hostname is used when it is unset
+	InstanceEnvVariable = "POD_NAME"
+)
+
+// serviceInfo is the identity set by SetServiceInfo
+type serviceInfo struct {
+	name     string
+	version  string
+	instance string
+}
+
+// SetServiceInfo sets the service, version and instance fields written on
+// every line of this logger and of the loggers derived from it, the
+// identity most observability platforms group lines by. An empty name
+// keeps Config.Service, an empty version defaults to SERVICE_VERSION and an
+// empty instance to POD_NAME, then to the hostname. Members still empty are
+// left out.
+func (l *Log) SetServiceInfo(name, version, instance string) {
+	if name == "" {
+		name = l.config.Service
+	}
+	if version == "" {
+		version = os.Getenv(ServiceVersionEnvVariable)
+	}
+	if instance == "" {
+		instance = os.Getenv(InstanceEnvVariable)
+	}
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+
+	l.serviceInfo.Store(&serviceInfo{name: name, version: version, instance: instance})
+}
+
+func (lp *LogParams) injectServiceInfo(info *serviceInfo) *LogParams {
+	if info == nil {
+		return lp
+	}
+
+	for key, value := range map[string]string{ServiceKey: info.name, VersionKey: info.version, InstanceKey: info.instance} {
+		if value != "" {
+			lp.fields[key] = value
+		}
+	}
+	return lp
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+)
+
+func TestSetServiceInfo(t *testing.T) {
+	t.Setenv(ServiceVersionEnvVariable, "")
+	t.Setenv(InstanceEnvVariable, "")
+
+	serviceLogger, hook := NewLoggerWithTestHook(sampleString)
+	serviceLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	serviceLogger.SetServiceInfo("orders", "1.4.2", "orders-7f9c")
+	serviceLogger.Named("db").Info(sampleContext, sampleString)
+
+	data := hook.LastEntry().Data
+	assert.Equal(t, "orders", data[ServiceKey])
+	assert.Equal(t, "1.4.2", data[VersionKey])
+	assert.Equal(t, "orders-7f9c", data[InstanceKey])
+
+	// defaults come from Config.Service, the environment and the hostname
+	hostname, _ := os.Hostname()
+	serviceLogger.SetServiceInfo("", "", "")
+	serviceLogger.Info(sampleContext, sampleString)
+	data = hook.LastEntry().Data
+	assert.Equal(t, sampleString, data[ServiceKey])
+	_, ok := data[VersionKey]
+	assert.False(t, ok)
+	assert.Equal(t, hostname, data[InstanceKey])
+
+	t.Setenv(ServiceVersionEnvVariable, "2.0.0")
+	t.Setenv(InstanceEnvVariable, "orders-pod")
+	serviceLogger.SetServiceInfo("", "", "")
+	serviceLogger.Info(sampleContext, sampleString)
+	data = hook.LastEntry().Data
+	assert.Equal(t, "2.0.0", data[VersionKey])
+	assert.Equal(t, "orders-pod", data[InstanceKey])
+}
@@ -0,0 +1,97 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// shutdown holds the hooks registered with OnShutdown, shared by every
+// logger derived from the same root
+type shutdown struct {
+	mu    sync.Mutex
+	hooks []func()
+}
+
+// OnShutdown registers fn to run when Shutdown is called, before the outputs
+// are flushed. Hooks run in registration order.
+func (l *Log) OnShutdown(fn func()) {
+	l.shutdown.mu.Lock()
+	defer l.shutdown.mu.Unlock()
+
+	l.shutdown.hooks = append(l.shutdown.hooks, fn)
+}
+
+// Shutdown runs the OnShutdown hooks, then flushes every output and closes
+// those that can be closed, stdout and stderr excepted. It gives up when ctx
+// is done and returns an error saying the flush did not complete.
+func (l *Log) Shutdown(ctx context.Context) error {
+	l.shutdown.mu.Lock()
+	hooks := l.shutdown.hooks
+	l.shutdown.hooks = nil
+	l.shutdown.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		for _, hook := range hooks {
+			hook()
+		}
+		done <- closeOutputs(l.outputs())
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("log: shutdown did not complete: %w", ctx.Err())
+	}
+}
+
+// outputs lists every writer the logger writes to
+func (l *Log) outputs() []io.Writer {
+	out := l.entry.Logger.Out
+	if _, async := out.(*asyncWriter); !async {
+		out = unwrapWriter(out)
+	}
+
+	writers := []io.Writer{out}
+	for _, output := range l.config.Outputs {
+		writers = append(writers, output.Writer)
+	}
+
+	return writers
+}
+
+// flushOutputs writes the lines the outputs still hold, e.g. queued by
+// Config.AsyncBuffer, before a Fatal line exits or a Panic line unwinds
+func (l *Log) flushOutputs() {
+	for _, w := range l.outputs() {
+		if flusher, ok := w.(interface{ Flush() error }); ok {
+			flusher.Flush()
+		}
+	}
+}
+
+func closeOutputs(writers []io.Writer) error {
+	var errs []error
+	for _, w := range writers {
+		switch flusher := w.(type) {
+		case interface{ Flush() error }:
+			errs = append(errs, flusher.Flush())
+		case interface{ Sync() error }:
+			// syncing a terminal fails on most platforms
+			if w != os.Stdout && w != os.Stderr {
+				errs = append(errs, flusher.Sync())
+			}
+		}
+
+		if closer, ok := w.(io.Closer); ok && w != os.Stdout && w != os.Stderr {
+			errs = append(errs, closer.Close())
+		}
+	}
+
+	return errors.Join(errs...)
+}
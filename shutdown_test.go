@@ -0,0 +1,44 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/c2fo/testify/assert"
+)
+
+type closingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closingBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestShutdownRunsHooksAndClosesOutputs(t *testing.T) {
+	out := &closingBuffer{}
+	shutdownLogger := NewLogger(sampleString)
+	shutdownLogger.GetEntry().Logger.Out = out
+
+	var order []int
+	shutdownLogger.OnShutdown(func() { order = append(order, 1) })
+	shutdownLogger.OnShutdown(func() { order = append(order, 2) })
+
+	assert.Nil(t, shutdownLogger.Shutdown(context.Background()))
+	assert.Equal(t, []int{1, 2}, order)
+	assert.True(t, out.closed)
+}
+
+func TestShutdownTimesOut(t *testing.T) {
+	shutdownLogger := NewLogger(sampleString)
+	shutdownLogger.OnShutdown(func() { time.Sleep(time.Second) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	assert.NotNil(t, shutdownLogger.Shutdown(ctx))
+}
@@ -0,0 +1,126 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Slog returns a *slog.Logger bound to ctx whose records go through this
+// logger: they carry the context id and the other context fields, the
+// service, follow the level, sampling and redaction of this logger, and
+// report the caller of the slog method. Attributes become fields, grouped
+// ones under "group.key". The ctx given to the slog Context methods is
+// ignored in favour of the bound one.
+//
+// slog levels map to Error from slog.LevelError, Warn from slog.LevelWarn,
+// Info from slog.LevelInfo, Debug from slog.LevelDebug and Trace below.
+func (l *Log) Slog(ctx context.Context) *slog.Logger {
+	return slog.New(&slogHandler{log: l, ctx: ctx})
+}
+
+// slogHandler is the slog.Handler behind Slog
+type slogHandler struct {
+	log *Log
+	ctx context.Context
+
+	// fields are the attributes added by WithAttrs, keys prefixed
+	fields []slogField
+
+	// prefix is the group path of the attributes to come, "a.b." or empty
+	prefix string
+}
+
+type slogField struct {
+	key   string
+	value interface{}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	logLevel := slogLevel(level)
+	return h.log.levels.enabled(h.log.category, logLevel) || requestLevelEnabled(h.ctx, logLevel)
+}
+
+func (h *slogHandler) Handle(_ context.Context, record slog.Record) error {
+	level := slogLevel(record.Level)
+	if !h.log.enabled(h.ctx, level) {
+		return nil
+	}
+
+	lp := h.log.newLogParams(h.ctx, level)
+	if _, ok := lp.fields[FuncKey]; ok && record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		lp.setCaller(&frame)
+	}
+	for _, field := range h.fields {
+		lp.fields[field.key] = field.value
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		for _, field := range slogFields(h.prefix, attr, nil) {
+			lp.fields[field.key] = field.value
+		}
+		return true
+	})
+
+	h.log.emit(level, lp, record.Message)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := append([]slogField(nil), h.fields...)
+	for _, attr := range attrs {
+		fields = slogFields(h.prefix, attr, fields)
+	}
+
+	handler := *h
+	handler.fields = fields
+	return &handler
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	handler := *h
+	handler.prefix = h.prefix + name + "."
+	return &handler
+}
+
+// slogFields appends the fields of attr to fields, groups flattened
+func slogFields(prefix string, attr slog.Attr, fields []slogField) []slogField {
+	value := attr.Value.Resolve()
+	if value.Kind() != slog.KindGroup {
+		if attr.Key == "" {
+			return fields
+		}
+		return append(fields, slogField{key: prefix + attr.Key, value: value.Any()})
+	}
+
+	// an unnamed group is inlined, as slog handlers do
+	if attr.Key != "" {
+		prefix += attr.Key + "."
+	}
+	for _, member := range value.Group() {
+		fields = slogFields(prefix, member, fields)
+	}
+	return fields
+}
+
+// slogLevel maps a slog level to the logrus level at or below it
+func slogLevel(level slog.Level) log.Level {
+	switch {
+	case level >= slog.LevelError:
+		return log.ErrorLevel
+	case level >= slog.LevelWarn:
+		return log.WarnLevel
+	case level >= slog.LevelInfo:
+		return log.InfoLevel
+	case level >= slog.LevelDebug:
+		return log.DebugLevel
+	}
+
+	return log.TraceLevel
+}
@@ -0,0 +1,43 @@
+package log
+
+import (
+	"io/ioutil"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestSlogRoutesThroughLogger(t *testing.T) {
+	slogLogger, hook := NewLoggerWithTestHook(sampleString)
+	slogLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	logger := slogLogger.Slog(sampleContext).With("user_id", 42).WithGroup("http")
+	logger.Info("served", "status", 200, slog.Group("client", "ip", "10.0.0.1"))
+
+	entry := hook.LastEntry()
+	assert.Equal(t, "served", entry.Message)
+	assert.Equal(t, log.InfoLevel, entry.Level)
+	assert.Equal(t, "11", entry.Data[ContextIdKey])
+	assert.Equal(t, sampleString, entry.Data["service"])
+	assert.Equal(t, int64(42), entry.Data["user_id"])
+	assert.Equal(t, int64(200), entry.Data["http.status"])
+	assert.Equal(t, "10.0.0.1", entry.Data["http.client.ip"])
+
+	logger.Debug("hidden")
+	assert.Equal(t, "served", hook.LastEntry().Message)
+
+	slogLogger.Slog(sampleContext).Error("failed")
+	assert.Equal(t, log.ErrorLevel, hook.LastEntry().Level)
+	assert.True(t, strings.HasSuffix(hook.LastEntry().Data[FuncKey].(string), "TestSlogRoutesThroughLogger"))
+}
+
+func TestSlogLevel(t *testing.T) {
+	assert.Equal(t, log.ErrorLevel, slogLevel(slog.LevelError+4))
+	assert.Equal(t, log.WarnLevel, slogLevel(slog.LevelWarn))
+	assert.Equal(t, log.InfoLevel, slogLevel(slog.LevelInfo+1))
+	assert.Equal(t, log.DebugLevel, slogLevel(slog.LevelDebug))
+	assert.Equal(t, log.TraceLevel, slogLevel(slog.LevelDebug-4))
+}
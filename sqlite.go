@@ -0,0 +1,279 @@
+//go:build sqlite
+
+package log
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SQLiteDriverName is the database/sql driver NewSQLiteLogger opens. The
+// package registers none, import one, e.g. _ "github.com/mattn/go-sqlite3"
+// for "sqlite3" or _ "modernc.org/sqlite" for "sqlite".
+var SQLiteDriverName = "sqlite3"
+
+// SQLiteRetention bounds the rows kept by a SQLite logger, the oldest are
+// deleted after each batch. A zero field disables its bound.
+type SQLiteRetention struct {
+	MaxRows int
+	MaxAge  time.Duration
+}
+
+const (
+	// sqliteBatchSize lines are inserted in one transaction
+	sqliteBatchSize = 100
+
+	// sqliteMaxPending bounds the lines waiting for their batch while the
+	// database fails, the oldest are dropped beyond it
+	sqliteMaxPending = 100 * sqliteBatchSize
+
+	// sqliteFlushInterval bounds how long a line waits for its batch
+	sqliteFlushInterval = time.Second
+
+	// sqliteTimeFormat sorts as text, so time ranges can be queried
+	sqliteTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS logs (
+	id      INTEGER PRIMARY KEY AUTOINCREMENT,
+	time    TEXT NOT NULL,
+	level   TEXT NOT NULL,
+	message TEXT NOT NULL,
+	fields  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS logs_time ON logs (time);`
+
+// NewSQLiteLogger builds a logger for service storing every line as a row
+// of the "logs" table of the SQLite database at dbPath, with the level,
+// the UTC time, the message and the fields as a JSON object, so embedded
+// and desktop apps can query their own logs. Rows are inserted in batches
+// of 100 or every second, on a goroutine of their own, and trimmed to the
+// last 100000 rows and 7 days. Shutdown writes the pending rows and closes
+// the database, later lines are dropped.
+//
+// It is only built with the sqlite build tag, and needs the driver named
+// by SQLiteDriverName to be registered.
+func NewSQLiteLogger(service, dbPath string) (Logger, error) {
+	return NewSQLiteLoggerWithRetention(service, dbPath, SQLiteRetention{MaxRows: 100000, MaxAge: 7 * 24 * time.Hour})
+}
+
+// NewSQLiteLoggerWithRetention is NewSQLiteLogger keeping the rows allowed
+// by retention. Failed inserts are reported like write errors, see
+// SetWriteErrorHandler, and retried with the next batch; past 10000 lines
+// waiting, the oldest are dropped.
+func NewSQLiteLoggerWithRetention(service, dbPath string, retention SQLiteRetention) (Logger, error) {
+	db, err := sql.Open(SQLiteDriverName, dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	logger := log.New()
+	l := newLog(logger, Config{Service: service})
+	logger.SetOutput(ioutil.Discard)
+
+	sink := &sqliteSink{
+		db:        db,
+		formatter: &fieldFormatter{Formatter: sqliteFieldsFormatter{}, config: l.config},
+		retention: retention,
+		errors:    l.writeErrors,
+		full:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	logger.AddHook(sink)
+	go sink.flushEvery(sqliteFlushInterval)
+	l.OnShutdown(sink.close)
+
+	return l, nil
+}
+
+type sqliteRow struct {
+	time    string
+	level   string
+	message string
+	fields  string
+}
+
+// sqliteSink batches the entries of a logger into the logs table
+type sqliteSink struct {
+	db        *sql.DB
+	formatter log.Formatter
+	retention SQLiteRetention
+	errors    *writeErrors
+
+	mu      sync.Mutex
+	pending []sqliteRow
+	closed  bool
+
+	// flushing serializes the flushes, mu is only held to take the rows
+	flushing sync.Mutex
+
+	// full wakes the flushing goroutine once a batch is complete
+	full    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func (s *sqliteSink) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire queues the entry, it never waits for the database
+func (s *sqliteSink) Fire(entry *log.Entry) error {
+	fields, err := s.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	if len(s.pending) >= sqliteMaxPending {
+		s.pending = s.pending[1:]
+	}
+	s.pending = append(s.pending, sqliteRow{
+		time:    entry.Time.UTC().Format(sqliteTimeFormat),
+		level:   entry.Level.String(),
+		message: entry.Message,
+		fields:  string(fields),
+	})
+	if len(s.pending) >= sqliteBatchSize {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (s *sqliteSink) flushEvery(interval time.Duration) {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		case <-s.full:
+		}
+		if err := s.flush(); err != nil {
+			s.errors.handle(err, nil)
+		}
+	}
+}
+
+// flush inserts the pending rows then applies the retention. The rows of a
+// failed insert are queued again before the rows fired meanwhile.
+func (s *sqliteSink) flush() error {
+	s.flushing.Lock()
+	defer s.flushing.Unlock()
+
+	s.mu.Lock()
+	rows := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	if len(rows) == 0 {
+		return nil
+	}
+	if err := s.insert(rows); err != nil {
+		s.mu.Lock()
+		s.pending = append(rows, s.pending...)
+		if excess := len(s.pending) - sqliteMaxPending; excess > 0 {
+			s.pending = s.pending[excess:]
+		}
+		s.mu.Unlock()
+		return err
+	}
+
+	return s.applyRetention()
+}
+
+func (s *sqliteSink) insert(rows []sqliteRow) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	insert, err := tx.Prepare("INSERT INTO logs (time, level, message, fields) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer insert.Close()
+
+	for _, row := range rows {
+		if _, err := insert.Exec(row.time, row.level, row.message, row.fields); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteSink) applyRetention() error {
+	if maxAge := s.retention.MaxAge; maxAge > 0 {
+		oldest := time.Now().Add(-maxAge).UTC().Format(sqliteTimeFormat)
+		if _, err := s.db.Exec("DELETE FROM logs WHERE time < ?", oldest); err != nil {
+			return err
+		}
+	}
+	if maxRows := s.retention.MaxRows; maxRows > 0 {
+		if _, err := s.db.Exec("DELETE FROM logs WHERE id <= (SELECT MAX(id) FROM logs) - ?", maxRows); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close writes the pending rows and closes the database, the entries fired
+// later are dropped
+func (s *sqliteSink) close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.done)
+	<-s.stopped
+
+	if err := s.flush(); err != nil {
+		s.errors.handle(err, nil)
+	}
+	s.db.Close()
+}
+
+// sqliteFieldsFormatter renders the fields of an entry as a JSON object
+type sqliteFieldsFormatter struct{}
+
+func (sqliteFieldsFormatter) Format(entry *log.Entry) ([]byte, error) {
+	fields := make(log.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		fields[key] = value
+	}
+
+	return json.Marshal(fields)
+}
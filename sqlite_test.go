@@ -0,0 +1,325 @@
+//go:build sqlite
+
+package log
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+// fakeSQLite keeps the logs table in memory, it understands the statements
+// of sqliteSink only
+type fakeSQLite struct {
+	mu     sync.Mutex
+	nextId int64
+	rows   []fakeSQLiteRow
+	fail   bool
+	closed bool
+}
+
+type fakeSQLiteRow struct {
+	id      int64
+	time    string
+	level   string
+	message string
+	fields  string
+}
+
+func (db *fakeSQLite) snapshot() []fakeSQLiteRow {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return append([]fakeSQLiteRow(nil), db.rows...)
+}
+
+func (db *fakeSQLite) setFail(fail bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.fail = fail
+}
+
+func (db *fakeSQLite) isClosed() bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.closed
+}
+
+func (db *fakeSQLite) exec(query string, args []driver.Value) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.fail {
+		return errors.New("database is locked")
+	}
+
+	switch {
+	case strings.Contains(query, "CREATE TABLE"):
+	case strings.HasPrefix(query, "INSERT"):
+		db.nextId++
+		db.rows = append(db.rows, fakeSQLiteRow{
+			id:      db.nextId,
+			time:    args[0].(string),
+			level:   args[1].(string),
+			message: args[2].(string),
+			fields:  args[3].(string),
+		})
+	case strings.HasPrefix(query, "DELETE FROM logs WHERE time <"):
+		kept := db.rows[:0]
+		for _, row := range db.rows {
+			if row.time >= args[0].(string) {
+				kept = append(kept, row)
+			}
+		}
+		db.rows = kept
+	case strings.HasPrefix(query, "DELETE FROM logs WHERE id <="):
+		kept := db.rows[:0]
+		for _, row := range db.rows {
+			if row.id > db.nextId-args[0].(int64) {
+				kept = append(kept, row)
+			}
+		}
+		db.rows = kept
+	default:
+		return errors.New("unexpected statement: " + query)
+	}
+
+	return nil
+}
+
+// fakeSQLiteDriver opens the fakeSQLite registered under the dsn
+type fakeSQLiteDriver struct {
+	mu        sync.Mutex
+	databases map[string]*fakeSQLite
+}
+
+var fakeSQLiteDrivers = &fakeSQLiteDriver{databases: map[string]*fakeSQLite{}}
+
+func init() {
+	sql.Register("fakesqlite", fakeSQLiteDrivers)
+}
+
+func (d *fakeSQLiteDriver) Open(dsn string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	db, ok := d.databases[dsn]
+	if !ok {
+		db = &fakeSQLite{}
+		d.databases[dsn] = db
+	}
+
+	return &fakeSQLiteConn{db: db}, nil
+}
+
+// reset empties the database of dsn, tests run more than once with -count
+func (d *fakeSQLiteDriver) reset(dsn string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.databases, dsn)
+}
+
+func (d *fakeSQLiteDriver) database(dsn string) *fakeSQLite {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.databases[dsn]
+}
+
+type fakeSQLiteConn struct {
+	db *fakeSQLite
+}
+
+func (c *fakeSQLiteConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLiteStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeSQLiteConn) Close() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.closed = true
+	return nil
+}
+
+// Begin runs the statements of the transaction as they come, the sink
+// does not depend on the rollback
+func (c *fakeSQLiteConn) Begin() (driver.Tx, error) {
+	return fakeSQLiteTx{}, nil
+}
+
+type fakeSQLiteTx struct{}
+
+func (fakeSQLiteTx) Commit() error   { return nil }
+func (fakeSQLiteTx) Rollback() error { return nil }
+
+type fakeSQLiteStmt struct {
+	db    *fakeSQLite
+	query string
+}
+
+func (s *fakeSQLiteStmt) Close() error  { return nil }
+func (s *fakeSQLiteStmt) NumInput() int { return -1 }
+
+func (s *fakeSQLiteStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.db.exec(s.query, args); err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+func (s *fakeSQLiteStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, io.EOF
+}
+
+func newFakeSQLiteLogger(t *testing.T, dsn string, retention SQLiteRetention) (*Log, *sqliteSink, *fakeSQLite) {
+	driverName := SQLiteDriverName
+	SQLiteDriverName = "fakesqlite"
+	defer func() { SQLiteDriverName = driverName }()
+	fakeSQLiteDrivers.reset(dsn)
+
+	logger, err := NewSQLiteLoggerWithRetention("svc", dsn, retention)
+	assert.Nil(t, err)
+	l := logger.(*Log)
+
+	return l, l.entry.Logger.Hooks[log.InfoLevel][0].(*sqliteSink), fakeSQLiteDrivers.database(dsn)
+}
+
+func TestSQLiteLoggerInsertsRows(t *testing.T) {
+	ctx := context.Background()
+	logger, _, db := newFakeSQLiteLogger(t, t.Name(), SQLiteRetention{})
+
+	logger.Infof(ctx, "hello %s", "world")
+	logger.Error(ctx, "failed")
+	assert.Nil(t, logger.Shutdown(ctx))
+
+	rows := db.snapshot()
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "hello world", rows[0].message)
+	assert.Equal(t, "info", rows[0].level)
+	assert.Equal(t, "error", rows[1].level)
+	assert.Contains(t, rows[0].fields, `"service":"svc"`)
+	assert.True(t, strings.HasSuffix(rows[0].time, "Z"))
+}
+
+func TestSQLiteLoggerFlushesFullBatches(t *testing.T) {
+	ctx := context.Background()
+	logger, _, db := newFakeSQLiteLogger(t, t.Name(), SQLiteRetention{})
+	defer logger.Shutdown(ctx)
+
+	for i := 0; i < sqliteBatchSize; i++ {
+		logger.Info(ctx, "line")
+	}
+
+	// the batch is written before the flush interval
+	deadline := time.Now().Add(sqliteFlushInterval / 2)
+	for len(db.snapshot()) < sqliteBatchSize && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, sqliteBatchSize, len(db.snapshot()))
+}
+
+func TestSQLiteLoggerAppliesItsRetention(t *testing.T) {
+	ctx := context.Background()
+	logger, _, db := newFakeSQLiteLogger(t, t.Name(), SQLiteRetention{MaxRows: 2})
+	for _, message := range []string{"one", "two", "three"} {
+		logger.Info(ctx, message)
+	}
+	assert.Nil(t, logger.Shutdown(ctx))
+
+	rows := db.snapshot()
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "two", rows[0].message)
+	assert.Equal(t, "three", rows[1].message)
+
+	// the retention of one logger leaves the other alone
+	expiring, _, db := newFakeSQLiteLogger(t, "expiring", SQLiteRetention{MaxAge: time.Nanosecond})
+	kept, _, keptDB := newFakeSQLiteLogger(t, "kept", SQLiteRetention{})
+	expiring.Info(ctx, "expired")
+	kept.Info(ctx, "kept")
+	time.Sleep(time.Millisecond)
+	assert.Nil(t, expiring.Shutdown(ctx))
+	assert.Nil(t, kept.Shutdown(ctx))
+
+	assert.Equal(t, 0, len(db.snapshot()))
+	assert.Equal(t, 1, len(keptDB.snapshot()))
+}
+
+func TestSQLiteLoggerRetriesFailedBatches(t *testing.T) {
+	ctx := context.Background()
+	logger, sink, db := newFakeSQLiteLogger(t, t.Name(), SQLiteRetention{})
+	logger.writeErrors.policy = DropWriteErrors
+
+	db.setFail(true)
+	logger.Info(ctx, "first")
+	assert.NotNil(t, sink.flush())
+
+	db.setFail(false)
+	logger.Info(ctx, "second")
+	assert.Nil(t, logger.Shutdown(ctx))
+
+	rows := db.snapshot()
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "first", rows[0].message)
+	assert.Equal(t, "second", rows[1].message)
+}
+
+func TestSQLiteLoggerReportsFailedBatches(t *testing.T) {
+	ctx := context.Background()
+	logger, _, db := newFakeSQLiteLogger(t, t.Name(), SQLiteRetention{})
+	logger.writeErrors.policy = DropWriteErrors
+	var reported int32
+	logger.SetWriteErrorHandler(func(error) { atomic.AddInt32(&reported, 1) })
+
+	db.setFail(true)
+	logger.Info(ctx, "lost")
+	assert.Nil(t, logger.Shutdown(ctx))
+
+	assert.True(t, atomic.LoadInt32(&reported) > 0)
+	assert.Equal(t, 0, len(db.snapshot()))
+}
+
+func TestSQLiteLoggerBoundsPendingRows(t *testing.T) {
+	ctx := context.Background()
+	logger, sink, db := newFakeSQLiteLogger(t, t.Name(), SQLiteRetention{})
+	logger.writeErrors.policy = DropWriteErrors
+	db.setFail(true)
+
+	for i := 0; i < sqliteMaxPending+10; i++ {
+		logger.Info(ctx, "line")
+	}
+	sink.flush()
+
+	sink.mu.Lock()
+	pending := len(sink.pending)
+	sink.mu.Unlock()
+	assert.Equal(t, sqliteMaxPending, pending)
+
+	db.setFail(false)
+	assert.Nil(t, logger.Shutdown(ctx))
+}
+
+func TestSQLiteLoggerDropsLinesAfterShutdown(t *testing.T) {
+	ctx := context.Background()
+	logger, _, db := newFakeSQLiteLogger(t, t.Name(), SQLiteRetention{})
+	logger.Info(ctx, "before")
+	assert.Nil(t, logger.Shutdown(ctx))
+	assert.True(t, db.isClosed())
+
+	assert.NotPanics(t, func() { logger.Info(ctx, "after") })
+	assert.Equal(t, 1, len(db.snapshot()))
+}
@@ -0,0 +1,24 @@
+package log
+
+import "context"
+
+// safe typing https://golang.org/pkg/context/#WithValue
+type streamIdKeyType string
+
+const streamIdKey streamIdKeyType = "stream_id"
+
+// WithStreamID returns a copy of ctx carrying the HTTP/2 stream id of the
+// request, for Middleware to add as a "stream_id" field to every line of
+// HTTP/2 requests. net/http does not expose stream ids, so it is meant for
+// servers and frameworks that know them, e.g. a custom HTTP/2 server
+// setting it on the request context before Middleware runs. HTTP/1
+// requests never get the field.
+func WithStreamID(ctx context.Context, id uint32) context.Context {
+	return context.WithValue(ctx, streamIdKey, id)
+}
+
+// StreamIDFromContext returns the stream id set by WithStreamID
+func StreamIDFromContext(ctx context.Context) (uint32, bool) {
+	id, ok := ctx.Value(streamIdKey).(uint32)
+	return id, ok
+}
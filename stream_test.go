@@ -0,0 +1,31 @@
+package log
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+)
+
+func TestMiddlewareStreamID(t *testing.T) {
+	httpLogger, hook := NewLoggerWithTestHook(sampleString)
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.ProtoMajor, r.ProtoMinor = 2, 0
+	r = r.WithContext(WithStreamID(r.Context(), 7))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	for _, entry := range hook.AllEntries() {
+		assert.Equal(t, "7", entry.Data[StreamIdKey])
+	}
+
+	// HTTP/1 requests get no field
+	hook.Reset()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), r.WithContext(WithStreamID(r.Context(), 7)))
+	_, ok := hook.LastEntry().Data[StreamIdKey]
+	assert.False(t, ok)
+}
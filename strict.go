@@ -0,0 +1,29 @@
+package log
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+// checkContextId reports, with Config.StrictContext, a line whose context
+// holds no context id
+func (l *Log) checkContextId(lp *LogParams) {
+	if _, ok := lp.fields[ContextIdKey]; ok {
+		return
+	}
+
+	caller := ""
+	if frame := getCaller(); frame != nil {
+		caller = frame.Function
+	}
+
+	if l.config.MissingContextId != nil {
+		l.config.MissingContextId(caller)
+		return
+	}
+	if _, reported := l.missingContextIds.LoadOrStore(caller, true); reported {
+		return
+	}
+
+	warning := &LogParams{fields: log.Fields{FuncKey: caller}, config: l.config}
+	l.emit(log.WarnLevel, warning, "log line without context id, propagate the request context")
+}
@@ -0,0 +1,47 @@
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestStrictContext(t *testing.T) {
+	strictLogger := NewLoggerWithConfig(Config{Service: sampleString, StrictContext: true})
+	strictLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(strictLogger.GetEntry().Logger)
+
+	strictLogger.Info(sampleContext, sampleString)
+	assert.Equal(t, 1, len(hook.AllEntries()))
+
+	for i := 0; i < 3; i++ {
+		strictLogger.Info(context.Background(), sampleString)
+	}
+
+	var warnings []*log.Entry
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == log.WarnLevel {
+			warnings = append(warnings, entry)
+		}
+	}
+	assert.Equal(t, 1, len(warnings))
+	assert.Equal(t, 5, len(hook.AllEntries()))
+
+	var missing []string
+	failingLogger := NewLoggerWithConfig(Config{
+		Service:       sampleString,
+		StrictContext: true,
+		MissingContextId: func(caller string) {
+			missing = append(missing, caller)
+		},
+	})
+	failingLogger.GetEntry().Logger.Out = ioutil.Discard
+	failingLogger.Info(context.Background(), sampleString)
+	failingLogger.Info(context.Background(), sampleString)
+	assert.Equal(t, 2, len(missing))
+	assert.NotEqual(t, "", missing[0])
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"context"
+	"time"
+)
+
+// safe typing https://golang.org/pkg/context/#WithValue
+type requestStartKeyType string
+
+const (
+	// requestStartKey holds the time.Time Middleware started serving a
+	// request at, kept as a value rather than in the string data map so it
+	// keeps its monotonic clock reading
+	requestStartKey requestStartKeyType = "request_start"
+
+	// timerStartKey holds the time.Time of StartTimer
+	timerStartKey requestStartKeyType = "timer_start"
+)
+
+// clockJumpThreshold is the least gap between the wall clock and the
+// monotonic clock reported by HTTPConfig.LogClockJumps, smaller gaps come
+// from reading both clocks at slightly different instants
+const clockJumpThreshold = 100 * time.Millisecond
+
+// wallClock reads the wall clock alone, it is moved in tests to simulate a
+// clock change
+var wallClock = func() time.Time {
+	return time.Now().Round(0)
+}
+
+func withRequestStart(ctx context.Context, start time.Time) context.Context {
+	return context.WithValue(ctx, requestStartKey, start)
+}
+
+func requestStart(ctx context.Context) (time.Time, bool) {
+	start, ok := ctx.Value(requestStartKey).(time.Time)
+	return start, ok
+}
+
+// RequestElapsed returns the time since Middleware started serving the
+// request of ctx, zero outside Middleware. It is measured on the monotonic
+// clock, so a wall clock change during the request never makes it
+// negative or wrong.
+func RequestElapsed(ctx context.Context) time.Duration {
+	start, ok := requestStart(ctx)
+	if !ok {
+		return 0
+	}
+
+	return time.Since(start)
+}
+
+// StartTimer returns a copy of ctx recording the current time, for
+// LogResult to report the duration of the operation run with ctx
+func StartTimer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, timerStartKey, time.Now())
+}
+
+// timerElapsed returns the time since StartTimer was called on ctx
+func timerElapsed(ctx context.Context) (time.Duration, bool) {
+	start, ok := ctx.Value(timerStartKey).(time.Time)
+	if !ok {
+		return 0, false
+	}
+
+	return time.Since(start), true
+}
+
+// clockJump returns how much more the wall clock moved since start than the
+// elapsed time measured on the monotonic clock
+func clockJump(start time.Time, elapsed time.Duration) time.Duration {
+	return wallClock().Sub(start.Round(0)) - elapsed
+}
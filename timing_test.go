@@ -0,0 +1,53 @@
+package log
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c2fo/testify/assert"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestMiddlewareClockJump(t *testing.T) {
+	httpLogger := NewLoggerWithConfig(Config{Service: sampleString, HTTP: HTTPConfig{
+		AccessLogStyle: AccessLogSummary,
+		LogClockJumps:  true,
+	}})
+	httpLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(httpLogger.GetEntry().Logger)
+
+	defer func(original func() time.Time) { wallClock = original }(wallClock)
+	handler := httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, ok := requestStart(r.Context())
+		assert.True(t, ok)
+		assert.True(t, strings.Contains(start.String(), " m="))
+
+		// the wall clock is set back an hour while the request runs
+		wallClock = func() time.Time {
+			return time.Now().Round(0).Add(-time.Hour)
+		}
+		assert.True(t, RequestElapsed(r.Context()) >= 0)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	data := hook.LastEntry().Data
+	duration := data[DurationKey].(int64)
+	assert.True(t, duration >= 0 && duration < 1000)
+	jump := data[ClockJumpKey].(int64)
+	assert.True(t, jump <= -3599000 && jump >= -3601000)
+
+	// a steady clock adds no field
+	wallClock = func() time.Time { return time.Now().Round(0) }
+	httpLogger.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	_, ok := hook.LastEntry().Data[ClockJumpKey]
+	assert.False(t, ok)
+
+	assert.Equal(t, time.Duration(0), RequestElapsed(context.Background()))
+}
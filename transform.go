@@ -0,0 +1,59 @@
+package log
+
+import (
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FieldTransform rewrites one field before it is written: it returns the key
+// and value to write, or keep false to drop the field.
+type FieldTransform func(key string, value interface{}) (newKey string, newValue interface{}, keep bool)
+
+// SetFieldTransform applies transform to every field of every line of this
+// logger and of the loggers derived from it, e.g. to rename keys for a
+// backend or to drop a noisy field. It runs last, once the context data,
+// the caller, the method fields and the redaction are applied, and before
+// the field processing of the formatter. Fields are visited in key order;
+// when two fields end up with the same key, the one visited last wins. The
+// fields of the logger, e.g. "category", "component" and those of WithFields
+// and WithStaticFields, are transformed like the others. The "service" and
+// "env" fields and the fields of SetServiceInfo are not, and they win over a
+// field the transform renames to their key. Nil removes the transform.
+func (l *Log) SetFieldTransform(transform FieldTransform) {
+	if transform == nil {
+		l.fieldTransform.Store(nil)
+		return
+	}
+
+	l.fieldTransform.Store(&transform)
+}
+
+// untransformedKeys are kept as they are by transformFields
+var untransformedKeys = map[string]bool{ServiceKey: true, EnvKey: true}
+
+// transformFields returns fields rewritten by transform
+func transformFields(fields log.Fields, transform FieldTransform) log.Fields {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	transformed := make(log.Fields, len(fields))
+	for _, key := range keys {
+		if untransformedKeys[key] {
+			continue
+		}
+		if newKey, newValue, keep := transform(key, fields[key]); keep {
+			transformed[newKey] = newValue
+		}
+	}
+	for key := range untransformedKeys {
+		if value, ok := fields[key]; ok {
+			transformed[key] = value
+		}
+	}
+
+	return transformed
+}
@@ -0,0 +1,95 @@
+package log
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	logrusTest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestSetFieldTransform(t *testing.T) {
+	transformLogger, hook := NewLoggerWithTestHook(sampleString)
+	transformLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	transformLogger.SetFieldTransform(func(key string, value interface{}) (string, interface{}, bool) {
+		switch key {
+		case "debug_blob":
+			return "", nil, false
+		case ContextIdKey:
+			return "request_id", value, true
+		case "email":
+			return key, strings.ToUpper(value.(string)), true
+		}
+		return key, value, true
+	})
+
+	transformLogger.Named("billing").InfoMap(sampleContext, map[string]interface{}{
+		"debug_blob": "...",
+		"email":      "a@b.c",
+	}, sampleString)
+
+	data := hook.LastEntry().Data
+	assert.Equal(t, "11", data["request_id"])
+	assert.Equal(t, "A@B.C", data["email"])
+	assert.Equal(t, sampleString, data["service"])
+	_, ok := data["debug_blob"]
+	assert.False(t, ok)
+	_, ok = data[ContextIdKey]
+	assert.False(t, ok)
+
+	transformLogger.SetFieldTransform(nil)
+	transformLogger.Info(sampleContext, sampleString)
+	assert.Equal(t, "11", hook.LastEntry().Data[ContextIdKey])
+}
+
+func TestFieldTransformCoversLoggerFields(t *testing.T) {
+	transformLogger := NewLoggerWithConfig(Config{Service: sampleString, Env: "staging"})
+	transformLogger.GetEntry().Logger.Out = ioutil.Discard
+	hook := logrusTest.NewLocal(transformLogger.GetEntry().Logger)
+
+	transformLogger.SetFieldTransform(func(key string, value interface{}) (string, interface{}, bool) {
+		switch key {
+		case CategoryKey, "region":
+			return "x_" + key, value, true
+		case ComponentKey:
+			return "", nil, false
+		case "owner":
+			// renamed onto a key the transform cannot take over
+			return ServiceKey, value, true
+		case "stage":
+			return EnvKey, value, true
+		}
+		return key, value, true
+	})
+
+	transformLogger.Named("billing").WithComponent("db").WithFields(map[string]interface{}{
+		"region": "id-1",
+		"owner":  "spoofed",
+		"stage":  "spoofed",
+	}).Info(sampleContext, sampleString)
+
+	data := hook.LastEntry().Data
+	assert.Equal(t, "billing", data["x_"+CategoryKey])
+	assert.Equal(t, "id-1", data["x_region"])
+	for _, key := range []string{CategoryKey, ComponentKey, "region", "owner", "stage"} {
+		_, ok := data[key]
+		assert.False(t, ok, key)
+	}
+
+	// service and env are left untouched, and win
+	assert.Equal(t, sampleString, data[ServiceKey])
+	assert.Equal(t, "staging", data[EnvKey])
+}
+
+func TestTransformFieldsCollisionIsDeterministic(t *testing.T) {
+	toSameKey := func(key string, value interface{}) (string, interface{}, bool) {
+		return "key", value, true
+	}
+
+	for i := 0; i < 10; i++ {
+		fields := transformFields(map[string]interface{}{"a": 1, "b": 2, "c": 3}, toSameKey)
+		assert.Equal(t, 3, fields["key"])
+	}
+}
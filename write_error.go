@@ -0,0 +1,134 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WriteErrorPolicy tells what happens to a line its writer failed to write.
+type WriteErrorPolicy int
+
+const (
+	// ReportWriteErrors prints the error on stderr, as logrus does.
+	ReportWriteErrors WriteErrorPolicy = iota
+
+	// FallbackToStderr writes the line to stderr instead.
+	FallbackToStderr
+
+	// DropWriteErrors loses the line silently.
+	DropWriteErrors
+)
+
+// writeErrors holds the handling of write errors shared by every logger
+// derived from the same root
+type writeErrors struct {
+	mu      sync.RWMutex
+	handler func(error)
+	policy  WriteErrorPolicy
+
+	// panicReported reports the first panic recovered by write only
+	panicReported sync.Once
+}
+
+// SetWriteErrorHandler calls handler with every error returned by the
+// writers the logger was built with, e.g. a full disk or a broken pipe, to
+// count them or switch to another writer. The handler runs on the logging
+// goroutine before Config.WriteErrorPolicy applies. Nil removes it.
+func (l *Log) SetWriteErrorHandler(handler func(error)) {
+	l.writeErrors.mu.Lock()
+	defer l.writeErrors.mu.Unlock()
+
+	l.writeErrors.handler = handler
+}
+
+func (e *writeErrors) handle(err error, line []byte) {
+	e.mu.RLock()
+	handler := e.handler
+	e.mu.RUnlock()
+
+	if handler != nil {
+		handler(err)
+	}
+
+	switch e.policy {
+	case FallbackToStderr:
+		os.Stderr.Write(line)
+	case DropWriteErrors:
+	default:
+		fmt.Fprintf(os.Stderr, "Failed to write to log, %v\n", err)
+	}
+}
+
+// fallbackOutput receives the lines write could not hand to logrus
+var fallbackOutput io.Writer = os.Stderr
+
+// write hands the line to logrus. A panic of a formatter or a hook is
+// recovered and the line written as plain text on stderr instead, so a
+// broken extension never takes the caller down; the first panic is
+// reported with its stack. Panic lines still panic once written. fields
+// already hold the fields of the logger, as emit processed them.
+func (l *Log) write(level log.Level, fields log.Fields, message string) {
+	entry := log.NewEntry(l.entry.Logger).WithFields(fields)
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if _, ok := recovered.(*log.Entry); ok {
+			// the panic of a Panic line, raised by logrus once written
+			l.flushOutputs()
+			panic(recovered)
+		}
+
+		l.writeErrors.panicReported.Do(func() {
+			fmt.Fprintf(fallbackOutput, "log: recovered panic in formatter or hook, %v\n%s", recovered, debug.Stack())
+		})
+		fmt.Fprintf(fallbackOutput, "%s %s context_id=%v %s\n", time.Now().Format(time.RFC3339Nano), level, fields[ContextIdKey], message)
+
+		if level <= log.PanicLevel {
+			l.flushOutputs()
+			entry.Level = level
+			entry.Message = message
+			panic(entry)
+		}
+	}()
+
+	entry.Log(level, message)
+}
+
+// errorWriter hands the errors of its writer to the write error handling,
+// the logger never sees them
+type errorWriter struct {
+	io.Writer
+	errors *writeErrors
+}
+
+func (w *errorWriter) Write(p []byte) (int, error) {
+	if _, err := w.Writer.Write(p); err != nil {
+		w.errors.handle(err, p)
+	}
+
+	return len(p), nil
+}
+
+// unwrapWriter returns the writer given by the user behind w
+func unwrapWriter(w io.Writer) io.Writer {
+	for {
+		switch wrapper := w.(type) {
+		case *asyncWriter:
+			w = wrapper.w
+		case *errorWriter:
+			w = wrapper.Writer
+		case *framedWriter:
+			w = wrapper.Writer
+		default:
+			return w
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriteErrorHandler(t *testing.T) {
+	failingLogger := NewLoggerWithConfig(Config{
+		Service:          sampleString,
+		Outputs:          []Output{{Writer: failingWriter{}}},
+		WriteErrorPolicy: DropWriteErrors,
+	})
+
+	var handled []error
+	failingLogger.SetWriteErrorHandler(func(err error) {
+		handled = append(handled, err)
+	})
+
+	failingLogger.Info(sampleContext, sampleString)
+	failingLogger.Named("db").Error(sampleContext, sampleString)
+	assert.Equal(t, 2, len(handled))
+	assert.Equal(t, "disk full", handled[0].Error())
+
+	failingLogger.SetWriteErrorHandler(nil)
+	failingLogger.Info(sampleContext, sampleString)
+	assert.Equal(t, 2, len(handled))
+}
+
+func TestErrorWriterHidesErrors(t *testing.T) {
+	w := &errorWriter{Writer: failingWriter{}, errors: &writeErrors{policy: DropWriteErrors}}
+
+	n, err := w.Write([]byte("line\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, failingWriter{}, unwrapWriter(w))
+}
+
+type panickingHook struct{}
+
+func (panickingHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (panickingHook) Fire(*log.Entry) error {
+	panic("broken hook")
+}
+
+func TestWriteRecoversHookPanic(t *testing.T) {
+	var fallback bytes.Buffer
+	defer func(original io.Writer) { fallbackOutput = original }(fallbackOutput)
+	fallbackOutput = &fallback
+
+	panickingLogger, _ := NewLoggerWithTestHook(sampleString)
+	panickingLogger.GetEntry().Logger.Out = ioutil.Discard
+	panickingLogger.GetEntry().Logger.AddHook(panickingHook{})
+
+	assert.NotPanics(t, func() {
+		panickingLogger.Info(sampleContext, "first")
+		panickingLogger.Named("db").Error(sampleContext, "second")
+	})
+
+	output := fallback.String()
+	assert.Equal(t, 1, strings.Count(output, "recovered panic in formatter or hook, broken hook"))
+	assert.True(t, strings.Contains(output, " info context_id=11 first\n"))
+	assert.True(t, strings.Contains(output, " error context_id=11 second\n"))
+
+	assert.Panics(t, func() {
+		panickingLogger.Log(sampleContext, log.PanicLevel, "third")
+	})
+}
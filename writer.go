@@ -0,0 +1,117 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maximumPendingLine caps the line a jsonLineWriter holds while waiting for
+// its newline
+const maximumPendingLine = 64 << 10
+
+// jsonLineWriter re-emits the JSON lines written to it through a logger
+type jsonLineWriter struct {
+	log *Log
+	ctx context.Context
+
+	mu sync.Mutex
+	// pending holds the start of a line not terminated yet
+	pending bytes.Buffer
+}
+
+// JSONLineWriter returns a writer for the output of a subprocess that
+// already logs JSON lines. Every line is parsed, its "level" and "msg" (or
+// "message") drive the level and message, the other keys become fields and
+// the line is logged with the service and context data of this logger. A
+// line that is not a JSON object is logged at Info with the line as message.
+// Keys of the line never replace the service, the context data or the other
+// fields of this logger, and fatal or panic lines are logged at Error so a
+// subprocess cannot stop this one. Lines are cut every 64 KiB, the parts
+// logged as text. Close logs the last line when the output did not end with
+// a newline.
+func (l *Log) JSONLineWriter(ctx context.Context) io.WriteCloser {
+	return &jsonLineWriter{log: l, ctx: ctx}
+}
+
+func (w *jsonLineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending.Write(p)
+	for {
+		i := bytes.IndexByte(w.pending.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+
+		line := bytes.TrimSpace(w.pending.Next(i + 1))
+		if len(line) > 0 {
+			w.writeLine(line)
+		}
+	}
+	for w.pending.Len() >= maximumPendingLine {
+		if line := bytes.TrimSpace(w.pending.Next(maximumPendingLine)); len(line) > 0 {
+			w.writeLine(line)
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close logs the line left unterminated, e.g. by a subprocess that exited
+// mid-line
+func (w *jsonLineWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	line := bytes.TrimSpace(w.pending.Bytes())
+	if len(line) > 0 {
+		w.writeLine(line)
+	}
+	w.pending.Reset()
+
+	return nil
+}
+
+func (w *jsonLineWriter) writeLine(line []byte) {
+	level := log.InfoLevel
+	message := string(line)
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(line, &fields); err == nil && fields != nil {
+		message = ""
+		if value, ok := fields["level"]; ok {
+			if parsed, err := log.ParseLevel(fmt.Sprint(value)); err == nil {
+				level = parsed
+			}
+			delete(fields, "level")
+		}
+		for _, key := range []string{"msg", "message"} {
+			if value, ok := fields[key]; ok {
+				message = fmt.Sprint(value)
+				delete(fields, key)
+				break
+			}
+		}
+	} else {
+		fields = nil
+	}
+
+	if level < log.ErrorLevel {
+		level = log.ErrorLevel
+	}
+
+	if !w.log.enabled(w.ctx, level) {
+		return
+	}
+
+	lp := w.log.newLogParams(w.ctx, level)
+	w.log.addDataMap(lp, fields)
+	w.log.emit(level, lp, message)
+}
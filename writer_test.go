@@ -0,0 +1,81 @@
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/c2fo/testify/assert"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestJSONLineWriter(t *testing.T) {
+	lineLogger, hook := NewLoggerWithTestHook(sampleString)
+	lineLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	w := lineLogger.JSONLineWriter(sampleContext)
+	fmt.Fprint(w, `{"level":"warning","msg":"disk low","free_mb":12}`+"\n"+`plain `)
+	fmt.Fprint(w, "text\n")
+
+	entries := hook.AllEntries()
+	assert.Equal(t, 2, len(entries))
+
+	assert.Equal(t, log.WarnLevel, entries[0].Level)
+	assert.Equal(t, "disk low", entries[0].Message)
+	assert.Equal(t, float64(12), entries[0].Data["free_mb"])
+	assert.Equal(t, "11", entries[0].Data[ContextIdKey])
+
+	assert.Equal(t, log.InfoLevel, entries[1].Level)
+	assert.Equal(t, "plain text", entries[1].Message)
+
+	// the line cannot replace the fields of the logger
+	w = lineLogger.Named("parent").JSONLineWriter(sampleContext)
+	fmt.Fprint(w, `{"msg":"spoofing","service":"child","category":"x","context_id":"x","pid":7}`+"\n")
+	entry := hook.LastEntry()
+	assert.Equal(t, sampleString, entry.Data[ServiceKey])
+	assert.Equal(t, "parent", entry.Data[CategoryKey])
+	assert.Equal(t, "11", entry.Data[ContextIdKey])
+	assert.Equal(t, float64(7), entry.Data["pid"])
+}
+
+func TestJSONLineWriterCutsLongLines(t *testing.T) {
+	lineLogger, hook := NewLoggerWithTestHook(sampleString)
+	lineLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	w := lineLogger.JSONLineWriter(sampleContext)
+	for i := 0; i < 5; i++ {
+		fmt.Fprint(w, strings.Repeat("x", maximumPendingLine/2))
+	}
+
+	entries := hook.AllEntries()
+	assert.Equal(t, 2, len(entries))
+	for _, entry := range entries {
+		assert.Equal(t, maximumPendingLine, len(entry.Message))
+	}
+
+	assert.Nil(t, w.Close())
+	assert.Equal(t, maximumPendingLine/2, len(hook.LastEntry().Message))
+}
+
+func TestJSONLineWriterCloseLogsPartialLine(t *testing.T) {
+	lineLogger, hook := NewLoggerWithTestHook(sampleString)
+	lineLogger.GetEntry().Logger.Out = ioutil.Discard
+
+	w := lineLogger.JSONLineWriter(sampleContext)
+	fmt.Fprint(w, "first\n"+`{"level":"error","msg":"exited`)
+	fmt.Fprint(w, ` mid-line"}`)
+	assert.Equal(t, 1, len(hook.AllEntries()))
+
+	assert.Nil(t, w.Close())
+	entries := hook.AllEntries()
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, log.ErrorLevel, entries[1].Level)
+	assert.Equal(t, "exited mid-line", entries[1].Message)
+
+	// the line is logged once, and an empty remainder not at all
+	assert.Nil(t, w.Close())
+	fmt.Fprint(w, "last\n  ")
+	assert.Nil(t, w.Close())
+	assert.Equal(t, 3, len(hook.AllEntries()))
+}